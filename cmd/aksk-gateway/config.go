@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/qingtao/aksk/v2/request"
+)
+
+// Config 网关的配置, 从JSON文件加载
+type Config struct {
+	// 监听的地址, 默认为:8080
+	Listen string `json:"listen"`
+	// 向上游传递已验证的访问密钥的头部名称, 默认为X-Authenticated-Access-Key
+	IdentityHeader string `json:"identity_header"`
+	// 签名头部名称的前缀, 默认为x-auth-
+	HeaderPrefix string `json:"header_prefix"`
+	// hash算法, 默认为sha256
+	Hash string `json:"hash"`
+	// 编码格式, 默认为base64
+	Encoding string `json:"encoding"`
+	// 接受的签名算法, 例如: sha256/base64
+	AllowedAlgorithms []string `json:"allowed_algorithms"`
+	// 可以接受的时间偏差, 例如: 60s
+	Skew Duration `json:"skew"`
+	// 签名密钥的作用范围
+	Region  string `json:"region"`
+	Service string `json:"service"`
+	// 受信任的代理的网段(CIDR)或者IP
+	TrustedProxies []string `json:"trusted_proxies"`
+	// 受信任的代理负责设置的头部: Forwarded, X-Forwarded-Host或者X-Forwarded-Prefix, 配置trusted_proxies时不能为空
+	ForwardedHeaders []string `json:"forwarded_headers"`
+	// 返回给客户端的错误不包含具体的原因
+	UniformErrors bool `json:"uniform_errors"`
+	// 访问密钥的存储
+	Credentials Credentials `json:"credentials"`
+	// 路由, 按照路径前缀最长匹配
+	Routes []Route `json:"routes"`
+}
+
+// Credentials 访问密钥的存储, 同时配置时先查找静态的密钥
+type Credentials struct {
+	// 访问密钥到私有密钥的映射
+	Static map[string]string `json:"static"`
+	// 使用主密钥派生私有密钥
+	Derive *Derive `json:"derive"`
+}
+
+// Derive 派生密钥的配置, 参考core.KeyDeriver
+type Derive struct {
+	// 签发新密钥时使用的主密钥ID
+	Current string `json:"current"`
+	// 主密钥ID到主密钥的映射, 主密钥使用base64编码
+	Masters map[string][]byte `json:"masters"`
+	// 访问密钥的前缀
+	Prefix string `json:"prefix"`
+}
+
+// Route 路由的上游和策略
+type Route struct {
+	// 路径前缀, 例如: /api/
+	PathPrefix string `json:"path_prefix"`
+	// 上游的地址, 例如: http://127.0.0.1:9000
+	Upstream string `json:"upstream"`
+	// 转发到上游时去掉路径前缀
+	StripPrefix bool `json:"strip_prefix"`
+	// 不校验签名, 直接转发
+	Public bool `json:"public"`
+	// 不校验body
+	SkipBody bool `json:"skip_body"`
+	// 拒绝旧的签名方案的请求
+	RejectLegacy bool `json:"reject_legacy"`
+	// 接受的最低版本, 例如: 3
+	MinVersion string `json:"min_version"`
+	// body策略: optional, required或者forbidden, 默认为optional
+	Body string `json:"body"`
+	// 接受声明UNSIGNED-PAYLOAD的请求
+	AllowUnsignedPayload bool `json:"allow_unsigned_payload"`
+	// 校验签名后解压缩body再转发
+	Decompress bool `json:"decompress"`
+	// 只允许这些访问密钥, 为空时允许所有的访问密钥
+	AccessKeys []string `json:"access_keys"`
+}
+
+// Duration 使用字符串表示的时间间隔, 例如: 60s
+type Duration time.Duration
+
+// UnmarshalJSON 实现json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// loadConfig 从文件name加载配置并设置默认值
+func loadConfig(name string) (*Config, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s error %w", name, err)
+	}
+	if err := cfg.check(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// check 检查配置并设置默认值
+func (cfg *Config) check() error {
+	if cfg.Listen == "" {
+		cfg.Listen = ":8080"
+	}
+	if cfg.IdentityHeader == "" {
+		cfg.IdentityHeader = "X-Authenticated-Access-Key"
+	}
+	if cfg.HeaderPrefix == "" {
+		cfg.HeaderPrefix = "x-auth-"
+	}
+	if cfg.Hash == "" {
+		cfg.Hash = "sha256"
+	}
+	if cfg.Encoding == "" {
+		cfg.Encoding = "base64"
+	}
+	if cfg.Skew == 0 {
+		cfg.Skew = Duration(60 * time.Second)
+	}
+	if len(cfg.Routes) == 0 {
+		return errors.New("no routes")
+	}
+	for i, r := range cfg.Routes {
+		if !strings.HasPrefix(r.PathPrefix, "/") {
+			return fmt.Errorf("route %d path prefix %q invalid", i, r.PathPrefix)
+		}
+		u, err := url.Parse(r.Upstream)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("route %d upstream %q invalid", i, r.Upstream)
+		}
+		if _, err := r.bodyPolicy(); err != nil {
+			return fmt.Errorf("route %d %w", i, err)
+		}
+	}
+	return nil
+}
+
+// options 返回签名算法的选项
+func (cfg *Config) options() ([]core.Option, error) {
+	h, err := core.LookupHash(cfg.Hash)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := core.LookupEncoder(cfg.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	opts := []core.Option{
+		core.WithHash(h),
+		core.WithEncoder(enc),
+		core.WithAcceptableSkew(time.Duration(cfg.Skew)),
+		core.WithScope(cfg.Region, cfg.Service),
+	}
+	if len(cfg.AllowedAlgorithms) > 0 {
+		opts = append(opts, core.WithAllowedAlgorithms(cfg.AllowedAlgorithms...))
+	}
+	return opts, nil
+}
+
+// keyGetter 返回查找私有密钥的函数
+func (c *Credentials) keyGetter() (core.KeyGetter, error) {
+	var deriver *core.KeyDeriver
+	if c.Derive != nil {
+		var err error
+		deriver, err = core.NewKeyDeriver(c.Derive.Current, c.Derive.Masters, core.WithPrefix(c.Derive.Prefix))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.Static) == 0 && deriver == nil {
+		return nil, errors.New("no credentials")
+	}
+	return func(ak string) (string, error) {
+		if sk, ok := c.Static[ak]; ok {
+			return sk, nil
+		}
+		if deriver != nil {
+			return deriver.SecretKey(ak)
+		}
+		return "", nil
+	}, nil
+}
+
+// bodyPolicy 返回路由的body策略
+func (r *Route) bodyPolicy() (request.BodyPolicy, error) {
+	switch r.Body {
+	case "", "optional":
+		return request.BodyOptional, nil
+	case "required":
+		return request.BodyRequired, nil
+	case "forbidden":
+		return request.BodyForbidden, nil
+	}
+	return 0, fmt.Errorf("body policy %q invalid", r.Body)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/middleware"
+	"github.com/qingtao/aksk/v2/request"
+)
+
+// gateway 按照路由校验请求的签名, 去掉签名头部并注入已验证的身份后转发到上游
+type gateway struct {
+	identityHeader string
+	routes         []*route
+}
+
+// route 路由和处理函数
+type route struct {
+	// 去掉末尾斜杠的路径前缀, 根路径为空字符串
+	prefix  string
+	handler http.Handler
+}
+
+// newRoute 新建路由, 路径前缀/api/和/api等价
+func newRoute(prefix string, handler http.Handler) *route {
+	return &route{prefix: strings.TrimRight(prefix, "/"), handler: handler}
+}
+
+// match 路径是否等于前缀或者在前缀的下一级, 例如: /health匹配/health和/health/live, 不匹配/healthz
+func (rt *route) match(p string) bool {
+	return p == rt.prefix || strings.HasPrefix(p, rt.prefix+"/")
+}
+
+// newGateway 使用配置新建网关
+func newGateway(cfg *Config) (*gateway, error) {
+	opts, err := cfg.options()
+	if err != nil {
+		return nil, err
+	}
+	getter, err := cfg.Credentials.keyGetter()
+	if err != nil {
+		return nil, err
+	}
+	names := request.NewHeaderNames(cfg.HeaderPrefix)
+	// 提前检查校验器的配置, middleware.New在配置错误时panic
+	for _, rc := range cfg.Routes {
+		vc := request.ValidatorConfig{KeyGetter: getter, MinVersion: request.Version(rc.MinVersion), TrustedProxies: cfg.TrustedProxies, ForwardedHeaders: cfg.ForwardedHeaders}
+		if _, err := request.NewValidator(vc, opts...); err != nil {
+			return nil, fmt.Errorf("route %s %w", rc.PathPrefix, err)
+		}
+	}
+	g := &gateway{identityHeader: cfg.IdentityHeader}
+	for _, rc := range cfg.Routes {
+		rc := rc
+		upstream, _ := url.Parse(rc.Upstream)
+		proxy := httputil.NewSingleHostReverseProxy(upstream)
+		if rc.Public {
+			g.routes = append(g.routes, newRoute(rc.PathPrefix, stripPrefix(rc, proxy)))
+			continue
+		}
+		policy, _ := rc.bodyPolicy()
+		mw := middleware.New(middleware.Config{
+			KeyGetter:            getter,
+			SkipBody:             rc.SkipBody,
+			HeaderNames:          names,
+			UniformErrors:        cfg.UniformErrors,
+			RejectLegacy:         rc.RejectLegacy,
+			MinVersion:           request.Version(rc.MinVersion),
+			BodyPolicy:           func(*http.Request) request.BodyPolicy { return policy },
+			AllowUnsignedPayload: func(*http.Request) bool { return rc.AllowUnsignedPayload },
+			Decompress:           rc.Decompress,
+			TrustedProxies:       cfg.TrustedProxies,
+			ForwardedHeaders:     cfg.ForwardedHeaders,
+		}, opts...)
+		next := stripPrefix(rc, proxy)
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ak, err := request.ParseAccessKey(r, names)
+			if err != nil || !rc.allowed(ak) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			stripAuthHeaders(r.Header, names)
+			r.Header.Set(g.identityHeader, ak)
+			next.ServeHTTP(w, r)
+		})
+		g.routes = append(g.routes, newRoute(rc.PathPrefix, mw.Handle(handler)))
+	}
+	// 最长的前缀优先匹配
+	sort.SliceStable(g.routes, func(i, j int) bool {
+		return len(g.routes[i].prefix) > len(g.routes[j].prefix)
+	})
+	return g, nil
+}
+
+// ServeHTTP 实现http.Handler
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 客户端不能伪造身份
+	delHeaderVariants(r.Header, g.identityHeader)
+	// 拒绝包含.., .或者//的路径, 避免匹配公开的路由之后由上游规范化到其他的路径
+	if !request.CanonicalPath(r.URL.Path) {
+		http.Error(w, "path is not canonical", http.StatusBadRequest)
+		return
+	}
+	for _, rt := range g.routes {
+		if rt.match(r.URL.Path) {
+			rt.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// delHeaderVariants 删除名称等于name的所有头部, 不区分大小写并且_和-等价:
+// CGI, WSGI, Rack和PHP等把_和-映射到同一个变量, 上游会把X_Authenticated_Access_Key当作身份头部
+func delHeaderVariants(header http.Header, name string) {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", "-"))
+	}
+	want := normalize(name)
+	for k := range header {
+		if normalize(k) == want {
+			delete(header, k)
+		}
+	}
+}
+
+// allowed 路由是否允许访问密钥ak
+func (r *Route) allowed(ak string) bool {
+	if len(r.AccessKeys) == 0 {
+		return true
+	}
+	for _, v := range r.AccessKeys {
+		if v == ak {
+			return true
+		}
+	}
+	return false
+}
+
+// stripPrefix 路由配置StripPrefix时去掉路径前缀, 并在X-Forwarded-Prefix中告知上游
+func stripPrefix(rc Route, next http.Handler) http.Handler {
+	if !rc.StripPrefix {
+		return next
+	}
+	prefix := strings.TrimRight(rc.PathPrefix, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		r.URL.RawPath = ""
+		r.Header.Set("X-Forwarded-Prefix", prefix)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// stripAuthHeaders 删除签名使用的头部, 上游不需要也不应该看到签名
+func stripAuthHeaders(header http.Header, names request.HeaderNames) {
+	for _, name := range names.List() {
+		header.Del(name)
+	}
+	if v := header.Get(request.HeaderAuthorization); len(v) >= len(request.AuthorizationScheme) &&
+		strings.EqualFold(v[:len(request.AuthorizationScheme)], request.AuthorizationScheme) {
+		header.Del(request.HeaderAuthorization)
+	}
+}
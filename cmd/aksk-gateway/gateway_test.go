@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/qingtao/aksk/v2/request"
+	"github.com/stretchr/testify/assert"
+)
+
+// upstreamRequest 上游收到的请求
+type upstreamRequest struct {
+	Path     string
+	Identity string
+	Prefix   string
+	Auth     bool
+	Body     string
+}
+
+func newUpstream(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		auth := false
+		for k := range r.Header {
+			if strings.HasPrefix(strings.ToLower(k), "x-auth-") {
+				auth = true
+			}
+		}
+		json.NewEncoder(w).Encode(upstreamRequest{
+			Path:     r.URL.Path,
+			Identity: identity(r.Header),
+			Prefix:   r.Header.Get("X-Forwarded-Prefix"),
+			Auth:     auth || r.Header.Get("Authorization") != "",
+			Body:     string(b),
+		})
+	}))
+}
+
+// identity 返回上游看到的所有身份, 与CGI一样_和-等价
+func identity(header http.Header) string {
+	var values []string
+	for k, v := range header {
+		if strings.EqualFold(strings.ReplaceAll(k, "_", "-"), "X-Authenticated-Access-Key") {
+			values = append(values, v...)
+		}
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+func TestGateway(t *testing.T) {
+	upstream := newUpstream(t)
+	defer upstream.Close()
+	deriver, _ := core.NewKeyDeriver("k1", map[string][]byte{"k1": []byte("0123456789abcdef")})
+	derived, _ := deriver.Issue()
+	cfg := &Config{
+		Credentials: Credentials{
+			Static: map[string]string{"123": "456", "789": "abc"},
+			Derive: &Derive{Current: "k1", Masters: map[string][]byte{"k1": []byte("0123456789abcdef")}},
+		},
+		Routes: []Route{
+			{PathPrefix: "/api/", Upstream: upstream.URL, StripPrefix: true},
+			{PathPrefix: "/api/admin/", Upstream: upstream.URL, AccessKeys: []string{"123"}},
+			{PathPrefix: "/api/upload", Upstream: upstream.URL, Body: "required", RejectLegacy: true},
+			{PathPrefix: "/health", Upstream: upstream.URL, Public: true},
+		},
+	}
+	assert.NoError(t, cfg.check())
+	g, err := newGateway(cfg)
+	assert.NoError(t, err)
+	server := httptest.NewServer(g)
+	defer server.Close()
+
+	do := func(ak, sk, method, path, body string, format request.Format, header map[string]string) (int, upstreamRequest) {
+		r, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		if ak != "" {
+			modifier, _ := request.NewModifier(request.ModifierConfig{AccessKey: ak, SecretKey: sk, Format: format})
+			if err := modifier(r); err != nil {
+				t.Fatalf("ModifyRequest() error = %v", err)
+			}
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		defer resp.Body.Close()
+		var got upstreamRequest
+		json.NewDecoder(resp.Body).Decode(&got)
+		return resp.StatusCode, got
+	}
+	tests := []struct {
+		name       string
+		ak, sk     string
+		method     string
+		path       string
+		body       string
+		format     request.Format
+		header     map[string]string
+		wantStatus int
+		want       upstreamRequest
+	}{
+		{
+			name: "OkSigned", ak: "123", sk: "456", method: http.MethodPost, path: "/api/users", body: "helloworld",
+			wantStatus: http.StatusOK, want: upstreamRequest{Path: "/users", Identity: "123", Prefix: "/api", Body: "helloworld"},
+		},
+		{
+			name: "OkAuthorization", ak: "123", sk: "456", method: http.MethodGet, path: "/api/users", format: request.FormatAuthorization,
+			wantStatus: http.StatusOK, want: upstreamRequest{Path: "/users", Identity: "123", Prefix: "/api"},
+		},
+		{
+			name: "OkDerived", ak: derived.AccessKey, sk: derived.SecretKey, method: http.MethodGet, path: "/api/users",
+			wantStatus: http.StatusOK, want: upstreamRequest{Path: "/users", Identity: derived.AccessKey, Prefix: "/api"},
+		},
+		{
+			name: "OkSpoofedIdentity", ak: "789", sk: "abc", method: http.MethodGet, path: "/api/users", header: map[string]string{"X-Authenticated-Access-Key": "123"},
+			wantStatus: http.StatusOK, want: upstreamRequest{Path: "/users", Identity: "789", Prefix: "/api"},
+		},
+		{
+			name: "OkSpoofedIdentityUnderscore", ak: "789", sk: "abc", method: http.MethodGet, path: "/api/users", header: map[string]string{"x_authenticated_access_key": "123"},
+			wantStatus: http.StatusOK, want: upstreamRequest{Path: "/users", Identity: "789", Prefix: "/api"},
+		},
+		{
+			name: "OkPublicSpoofedIdentityUnderscore", method: http.MethodGet, path: "/health", header: map[string]string{"X_Authenticated-Access_Key": "123"},
+			wantStatus: http.StatusOK, want: upstreamRequest{Path: "/health"},
+		},
+		{
+			name: "OkAccessKeyAllowed", ak: "123", sk: "456", method: http.MethodGet, path: "/api/admin/users",
+			wantStatus: http.StatusOK, want: upstreamRequest{Path: "/api/admin/users", Identity: "123"},
+		},
+		{
+			name: "OkPublic", method: http.MethodGet, path: "/health", header: map[string]string{"X-Authenticated-Access-Key": "123"},
+			wantStatus: http.StatusOK, want: upstreamRequest{Path: "/health"},
+		},
+		{name: "FailedUnsigned", method: http.MethodGet, path: "/api/users", wantStatus: http.StatusUnauthorized},
+		{name: "FailedWrongKey", ak: "123", sk: "abc", method: http.MethodGet, path: "/api/users", wantStatus: http.StatusUnauthorized},
+		{name: "FailedAccessKeyNotAllowed", ak: "789", sk: "abc", method: http.MethodGet, path: "/api/admin/users", wantStatus: http.StatusForbidden},
+		{name: "FailedBodyRequired", ak: "123", sk: "456", method: http.MethodPut, path: "/api/upload", wantStatus: http.StatusUnauthorized},
+		{name: "FailedNotFound", ak: "123", sk: "456", method: http.MethodGet, path: "/other", wantStatus: http.StatusNotFound},
+		{
+			name: "OkPublicSubpath", method: http.MethodGet, path: "/health/live",
+			wantStatus: http.StatusOK, want: upstreamRequest{Path: "/health/live"},
+		},
+		{name: "FailedDotSegments", method: http.MethodGet, path: "/health/../api/secret", wantStatus: http.StatusBadRequest},
+		{name: "FailedEncodedDotSegments", method: http.MethodGet, path: "/health/%2e%2e/api/secret", wantStatus: http.StatusBadRequest},
+		{name: "FailedDoubleSlash", method: http.MethodGet, path: "/health//api", wantStatus: http.StatusBadRequest},
+		{name: "FailedPrefixBoundary", method: http.MethodGet, path: "/healthz-admin", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, got := do(tt.ak, tt.sk, tt.method, tt.path, tt.body, tt.format, tt.header)
+			assert.Equal(t, tt.wantStatus, status)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "Ok", config: `{"skew": "30s", "credentials": {"static": {"123": "456"}}, "routes": [{"path_prefix": "/", "upstream": "http://127.0.0.1:9000"}]}`},
+		{name: "FailedJSON", config: `{`, wantErr: true},
+		{name: "FailedSkew", config: `{"skew": "30", "routes": [{"path_prefix": "/", "upstream": "http://127.0.0.1:9000"}]}`, wantErr: true},
+		{name: "FailedNoRoutes", config: `{}`, wantErr: true},
+		{name: "FailedPathPrefix", config: `{"routes": [{"path_prefix": "api", "upstream": "http://127.0.0.1:9000"}]}`, wantErr: true},
+		{name: "FailedUpstream", config: `{"routes": [{"path_prefix": "/", "upstream": "127.0.0.1:9000"}]}`, wantErr: true},
+		{name: "FailedBodyPolicy", config: `{"routes": [{"path_prefix": "/", "upstream": "http://127.0.0.1:9000", "body": "always"}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, tt.name+".json")
+			os.WriteFile(name, []byte(tt.config), 0o600)
+			_, err := loadConfig(name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	_, err := loadConfig(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestNewGateway(t *testing.T) {
+	routes := []Route{{PathPrefix: "/", Upstream: "http://127.0.0.1:9000"}}
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "Ok", cfg: Config{Credentials: Credentials{Static: map[string]string{"123": "456"}}, Routes: routes}},
+		{name: "FailedNoCredentials", cfg: Config{Routes: routes}, wantErr: true},
+		{name: "FailedDerive", cfg: Config{Credentials: Credentials{Derive: &Derive{Current: "k1"}}, Routes: routes}, wantErr: true},
+		{name: "FailedHash", cfg: Config{Hash: "md5", Credentials: Credentials{Static: map[string]string{"123": "456"}}, Routes: routes}, wantErr: true},
+		{name: "OkTrustedProxies", cfg: Config{TrustedProxies: []string{"10.0.0.0/8"}, ForwardedHeaders: []string{"X-Forwarded-Host"}, Credentials: Credentials{Static: map[string]string{"123": "456"}}, Routes: routes}},
+		{name: "FailedTrustedProxies", cfg: Config{TrustedProxies: []string{"ingress"}, ForwardedHeaders: []string{"X-Forwarded-Host"}, Credentials: Credentials{Static: map[string]string{"123": "456"}}, Routes: routes}, wantErr: true},
+		{name: "FailedNoForwardedHeaders", cfg: Config{TrustedProxies: []string{"10.0.0.0/8"}, Credentials: Credentials{Static: map[string]string{"123": "456"}}, Routes: routes}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, tt.cfg.check())
+			if _, err := newGateway(&tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("newGateway() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
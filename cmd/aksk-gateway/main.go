@@ -0,0 +1,58 @@
+// Command aksk-gateway 是使用aksk认证的反向代理, 用于保护不能修改的服务:
+// 校验请求的签名, 去掉签名头部, 在头部中注入已验证的访问密钥后按照路由转发到上游
+//
+// 用法:
+//
+//	aksk-gateway -config gateway.json
+//
+// 配置示例:
+//
+//	{
+//	  "listen": ":8080",
+//	  "identity_header": "X-Authenticated-Access-Key",
+//	  "credentials": {"static": {"ak": "sk"}},
+//	  "routes": [
+//	    {"path_prefix": "/api/", "upstream": "http://127.0.0.1:9000", "strip_prefix": true, "reject_legacy": true},
+//	    {"path_prefix": "/health", "upstream": "http://127.0.0.1:9000", "public": true}
+//	  ]
+//	}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "aksk-gateway: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("aksk-gateway", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	name := fs.String("config", "gateway.json", "config file")
+	listen := fs.String("listen", "", "listen address, overrides the config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*name)
+	if err != nil {
+		return err
+	}
+	if *listen != "" {
+		cfg.Listen = *listen
+	}
+	g, err := newGateway(cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("aksk-gateway listening on %s", cfg.Listen)
+	return http.ListenAndServe(cfg.Listen, g)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+// keygen 生成随机的access key和secret key
+func keygen(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("keygen", stdout)
+	akLen := fs.Int("ak-len", 20, "length of the random part of the access key")
+	skLen := fs.Int("sk-len", 40, "length of the secret key")
+	alphabet := fs.String("alphabet", core.AlphabetBase62, "characters used in the keys")
+	prefix := fs.String("prefix", "", "access key prefix, e.g. AKID")
+	checksum := fs.Bool("checksum", true, "append a checksum to the access key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := core.GenerateCredential(
+		core.WithKeyLength(*akLen, *skLen),
+		core.WithAlphabet(*alphabet),
+		core.WithPrefix(*prefix),
+		core.WithChecksum(*checksum),
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "access_key: %s\nsecret_key: %s\n", c.AccessKey, c.SecretKey)
+	return nil
+}
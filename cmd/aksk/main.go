@@ -0,0 +1,142 @@
+// Command aksk 用于调试aksk认证: 生成密钥, 对请求签名, 校验和解释请求的签名, 为旧客户端签名的转发代理
+//
+// 用法:
+//
+//	aksk keygen [flags]
+//	aksk sign -ak AK -sk SK [flags] URL
+//	aksk verify -sk SK [flags] < request.txt
+//	aksk explain -sk SK [flags] < request.txt
+//	aksk proxy -profiles profiles.json [flags]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/qingtao/aksk/v2/request"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		// 本地调试工具, 输出包含具体原因的详细信息
+		fmt.Fprintf(os.Stderr, "aksk: %s\n", core.Detail(err))
+		os.Exit(1)
+	}
+}
+
+const usage = `usage: aksk <command> [flags]
+
+commands:
+  keygen   generate a random access key and secret key
+  sign     sign a request and print the headers or a curl command
+  verify   verify a raw http request read from stdin
+  explain  print the canonical string and hashes of a raw http request read from stdin
+  proxy    run a local forward proxy that signs plain http requests of legacy clients
+`
+
+// command 子命令
+type command func(args []string, stdin io.Reader, stdout io.Writer) error
+
+var commands = map[string]command{
+	"keygen":  keygen,
+	"sign":    sign,
+	"verify":  verify,
+	"explain": explain,
+	"proxy":   serveProxy,
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+	return cmd(args[1:], stdin, stdout)
+}
+
+// authFlags 签名算法相关的参数
+type authFlags struct {
+	hash      string
+	encoding  string
+	algorithm string
+	allowed   string
+	skew      time.Duration
+	region    string
+	service   string
+	prefix    string
+	lenient   bool
+	allowWeak bool
+}
+
+func (f *authFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.hash, "hash", "sha256", "hash algorithm: "+strings.Join(core.Hashes(), ", "))
+	fs.StringVar(&f.encoding, "encoding", "base64", "encoding: "+strings.Join(core.Encoders(), ", "))
+	fs.StringVar(&f.algorithm, "algorithm", "", "named algorithm declared in the request, e.g. sha256/base64, overrides -hash and -encoding")
+	fs.StringVar(&f.allowed, "allowed-algorithms", "", "comma separated algorithms accepted when verifying")
+	fs.DurationVar(&f.skew, "skew", 60*time.Second, "acceptable timestamp skew")
+	fs.StringVar(&f.region, "region", "", "region of the signing key scope")
+	fs.StringVar(&f.service, "service", "", "service of the signing key scope")
+	fs.StringVar(&f.prefix, "header-prefix", "x-auth-", "prefix of the signature header names")
+	fs.BoolVar(&f.lenient, "lenient", false, "accept padded and unpadded signatures when verifying")
+	fs.BoolVar(&f.allowWeak, "allow-weak", false, "allow weak hash algorithms such as md5 and sha1 for legacy clients")
+}
+
+// options 转换为core.Option
+func (f *authFlags) options() ([]core.Option, error) {
+	lookupHash, lookupAlgorithm := core.LookupHash, core.LookupAlgorithm
+	if f.allowWeak {
+		lookupHash, lookupAlgorithm = core.LookupLegacyHash, core.LookupLegacyAlgorithm
+	}
+	h, err := lookupHash(f.hash)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := core.LookupEncoder(f.encoding)
+	if err != nil {
+		return nil, err
+	}
+	opts := []core.Option{
+		core.WithHash(h),
+		core.WithEncoder(enc),
+		core.WithAcceptableSkew(f.skew),
+		core.WithScope(f.region, f.service),
+	}
+	if f.algorithm != "" {
+		alg, err := lookupAlgorithm(f.algorithm)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, core.WithAlgorithm(alg))
+	}
+	if f.lenient {
+		opts = append(opts, core.WithLenientDecoding())
+	}
+	if f.allowed != "" {
+		allowed := strings.Split(f.allowed, ",")
+		opts = append(opts, core.WithAllowedAlgorithms(allowed...))
+		if f.allowWeak {
+			opts = append(opts, core.WithLegacyAlgorithms(allowed...))
+		}
+	}
+	return opts, nil
+}
+
+// headerNames 返回签名头部的名称
+func (f *authFlags) headerNames() request.HeaderNames {
+	return request.NewHeaderNames(f.prefix)
+}
+
+// newFlagSet 新建子命令的参数集合, 错误由调用方返回
+func newFlagSet(name string, stdout io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	return fs
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/qingtao/aksk/v2/request"
+)
+
+// signedDump 返回已签名请求的原始内容
+func signedDump(t *testing.T, body string, opts ...core.Option) []byte {
+	return signedDumpContext(t, context.TODO(), body, opts...)
+}
+
+// signedDumpContext 使用ctx创建请求, 返回已签名请求的原始内容
+func signedDumpContext(t *testing.T, ctx context.Context, body string, opts ...core.Option) []byte {
+	modifier, _ := request.NewModifierFunc("123", "456", false, opts...)
+	r, _ := http.NewRequestWithContext(ctx, "POST", "http://example.com/", strings.NewReader(body))
+	if err := modifier(r); err != nil {
+		t.Fatalf("modifier error = %v", err)
+	}
+	b, err := httputil.DumpRequestOut(r, true)
+	if err != nil {
+		t.Fatalf("httputil.DumpRequestOut error = %v", err)
+	}
+	return b
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	bodyFile := filepath.Join(dir, "body.json")
+	os.WriteFile(bodyFile, []byte(`helloworld`), 0o600)
+	jsonFile := filepath.Join(dir, "object.json")
+	os.WriteFile(jsonFile, []byte(`{"b": 1, "a": 2}`), 0o600)
+
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   []byte
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "NoCommand",
+			wantErr: true,
+		},
+		{
+			name:    "UnknownCommand",
+			args:    []string{"unknown"},
+			wantErr: true,
+		},
+		{
+			name: "Keygen",
+			args: []string{"keygen", "-ak-len", "16", "-prefix", "AKID"},
+			want: []string{"access_key: AKID", "secret_key: "},
+		},
+		{
+			name:    "KeygenInvalidLength",
+			args:    []string{"keygen", "-sk-len", "0"},
+			wantErr: true,
+		},
+		{
+			name: "KeygenDefault",
+			args: []string{"keygen"},
+			want: []string{"access_key: ", "secret_key: "},
+		},
+		{
+			name: "Sign",
+			args: []string{"sign", "-ak", "123", "-sk", "456", "-d", bodyFile, "http://example.com/"},
+			want: []string{"X-Auth-Access-Key: 123", "X-Auth-Timestamp: ", "X-Auth-Body-Hash: ", "X-Auth-Signature: "},
+		},
+		{
+			name: "SignCurl",
+			args: []string{"sign", "-ak", "123", "-sk", "456", "-curl", "-H", "Content-Type: application/json", "-d", bodyFile, "http://example.com/"},
+			want: []string{"curl -X POST", "--data-binary '@" + bodyFile + "'", "'Content-Type: application/json'"},
+		},
+		{
+			name: "SignAuthorization",
+			args: []string{"sign", "-ak", "123", "-sk", "456", "-format", "authorization", "-version", "3", "-signed-headers", "host", "http://example.com/"},
+			want: []string{"Authorization: AKSK-HMAC ", "Credential=123, Timestamp=", "SignedHeaders=host"},
+		},
+		{
+			name:    "SignInvalidFormat",
+			args:    []string{"sign", "-ak", "123", "-sk", "456", "-format", "query", "http://example.com/"},
+			wantErr: true,
+		},
+		{
+			name:    "SignWeakHash",
+			args:    []string{"sign", "-ak", "123", "-sk", "456", "-hash", "sha1", "http://example.com/"},
+			wantErr: true,
+		},
+		{
+			name: "SignWeakHashAllowed",
+			args: []string{"sign", "-ak", "123", "-sk", "456", "-hash", "sha1", "-allow-weak", "http://example.com/"},
+			want: []string{"X-Auth-Signature: "},
+		},
+		{
+			name: "SignAlgorithm",
+			args: []string{"sign", "-ak", "123", "-sk", "456", "-algorithm", "sha3-256/base64url", "http://example.com/"},
+			want: []string{"X-Auth-Algorithm: sha3-256/base64url"},
+		},
+		{
+			name: "SignVersion",
+			args: []string{"sign", "-ak", "123", "-sk", "456", "-version", "2", "http://example.com/"},
+			want: []string{"X-Auth-Version: 2"},
+		},
+		{
+			name:    "SignInvalidVersion",
+			args:    []string{"sign", "-ak", "123", "-sk", "456", "-version", "9", "http://example.com/"},
+			wantErr: true,
+		},
+		{
+			name: "SignUnsignedPayload",
+			args: []string{"sign", "-ak", "123", "-sk", "456", "-unsigned-payload", "-d", bodyFile, "http://example.com/"},
+			want: []string{"X-Auth-Body-Hash: UNSIGNED-PAYLOAD"},
+		},
+		{
+			name: "SignBodyHashMode",
+			args: []string{"sign", "-ak", "123", "-sk", "456", "-body-hash-mode", "decoded", "-d", bodyFile, "http://example.com/"},
+			want: []string{"X-Auth-Body-Hash-Mode: decoded"},
+		},
+		{
+			name:    "SignCanonicalization",
+			args:    []string{"sign", "-ak", "123", "-sk", "456", "-canonicalization", "jcs", "-H", "Content-Type: application/json", "-d", bodyFile, "http://example.com/"},
+			wantErr: true,
+		},
+		{
+			name:    "SignInvalidHash",
+			args:    []string{"sign", "-ak", "123", "-sk", "456", "-hash", "md4", "http://example.com/"},
+			wantErr: true,
+		},
+		{
+			name:  "Verify",
+			args:  []string{"verify", "-sk", "456"},
+			stdin: signedDump(t, "helloworld"),
+			want:  []string{"OK"},
+		},
+		{
+			name:    "VerifyWrongSecret",
+			args:    []string{"verify", "-sk", "789"},
+			stdin:   signedDump(t, "helloworld"),
+			wantErr: true,
+		},
+		{
+			name:    "VerifyRejectLegacy",
+			args:    []string{"verify", "-sk", "456", "-reject-legacy"},
+			stdin:   signedDump(t, "helloworld"),
+			wantErr: true,
+		},
+		{
+			name:  "VerifyUnsignedPayload",
+			args:  []string{"verify", "-sk", "456", "-allow-unsigned-payload"},
+			stdin: signedDumpContext(t, request.WithUnsignedPayload(context.TODO()), "helloworld"),
+			want:  []string{"OK"},
+		},
+		{
+			name:    "VerifyUnsignedPayloadNotAllowed",
+			args:    []string{"verify", "-sk", "456"},
+			stdin:   signedDumpContext(t, request.WithUnsignedPayload(context.TODO()), "helloworld"),
+			wantErr: true,
+		},
+		{
+			name:  "VerifyScope",
+			args:  []string{"verify", "-sk", "456", "-region", "cn-north", "-service", "storage"},
+			stdin: signedDump(t, "helloworld", core.WithScope("cn-north", "storage")),
+			want:  []string{"OK"},
+		},
+		{
+			name:  "ExplainScope",
+			args:  []string{"explain", "-sk", "456"},
+			stdin: signedDump(t, "helloworld", core.WithScope("cn-north", "storage")),
+			want:  []string{"scope:", "signing key:"},
+		},
+		{
+			name:  "Explain",
+			args:  []string{"explain", "-sk", "456"},
+			stdin: signedDump(t, "helloworld"),
+			want:  []string{"canonical string:", "k2oYXKqiZrucvpgengXLeM1zKwsygOuURBK7b4+PB68="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := run(tt.args, bytes.NewReader(tt.stdin), &stdout)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(stdout.String(), s) {
+					t.Errorf("expect output contains %q, but got %q", s, stdout.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/qingtao/aksk/v2/request"
+)
+
+// profile 上游主机使用的密钥和签名参数
+type profile struct {
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	// 使用https连接上游, 客户端只需要发送http请求
+	HTTPS bool `json:"https"`
+	// 签名方案的版本
+	Version string `json:"version"`
+	// 签名头部的格式: headers, authorization
+	Format string `json:"format"`
+	// 参与签名的其他头部名称
+	SignedHeaders []string `json:"signed_headers"`
+	// 不对body签名
+	SkipBody bool `json:"skip_body"`
+}
+
+// loadProfiles 从文件name加载主机到密钥的映射, 主机可以包含端口, 或者使用*.example.com匹配子域名
+func loadProfiles(name string) (map[string]*profile, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]*profile)
+	if err := json.Unmarshal(b, &profiles); err != nil {
+		return nil, fmt.Errorf("parse profiles %s error %w", name, err)
+	}
+	if len(profiles) == 0 {
+		return nil, errors.New("no profiles")
+	}
+	return profiles, nil
+}
+
+// signingProxy 对普通的http请求签名后转发的代理, 用于不能修改的旧客户端
+type signingProxy struct {
+	proxies map[string]*httputil.ReverseProxy
+}
+
+// newSigningProxy 为每个主机新建签名的反向代理
+func newSigningProxy(profiles map[string]*profile, names request.HeaderNames, opts []core.Option) (*signingProxy, error) {
+	p := &signingProxy{proxies: make(map[string]*httputil.ReverseProxy, len(profiles))}
+	for host, pf := range profiles {
+		cfg := request.ModifierConfig{
+			AccessKey:     pf.AccessKey,
+			SecretKey:     pf.SecretKey,
+			SkipBody:      pf.SkipBody,
+			HeaderNames:   names,
+			Version:       request.Version(pf.Version),
+			SignedHeaders: pf.SignedHeaders,
+		}
+		switch pf.Format {
+		case "", "headers":
+			cfg.Format = request.FormatHeaders
+		case "authorization":
+			cfg.Format = request.FormatAuthorization
+		default:
+			return nil, fmt.Errorf("profile %q format %q invalid", host, pf.Format)
+		}
+		if cfg.AccessKey == "" || cfg.SecretKey == "" {
+			return nil, fmt.Errorf("profile %q has no key", host)
+		}
+		modifier, err := request.NewModifier(cfg, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q %w", host, err)
+		}
+		https := pf.HTTPS
+		p.proxies[strings.ToLower(host)] = &httputil.ReverseProxy{
+			Director: func(r *http.Request) {
+				if https {
+					r.URL.Scheme = "https"
+				}
+				// 不向上游透露客户端的地址
+				r.Header["X-Forwarded-For"] = nil
+			},
+			Transport: &request.Transport{Modifier: modifier},
+		}
+	}
+	return p, nil
+}
+
+// lookup 查找主机的代理, 依次匹配host:port, host和*.域名
+func (p *signingProxy) lookup(hostport, host string) *httputil.ReverseProxy {
+	hostport, host = strings.ToLower(hostport), strings.ToLower(host)
+	if proxy, ok := p.proxies[hostport]; ok {
+		return proxy
+	}
+	if proxy, ok := p.proxies[host]; ok {
+		return proxy
+	}
+	for s := host; ; {
+		i := strings.IndexByte(s, '.')
+		if i < 0 {
+			return nil
+		}
+		s = s[i+1:]
+		if proxy, ok := p.proxies["*."+s]; ok {
+			return proxy
+		}
+	}
+}
+
+// ServeHTTP 实现http.Handler
+func (p *signingProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		// 隧道中的https请求无法签名, 客户端使用http://并在配置中设置https
+		http.Error(w, "CONNECT is not supported, use http:// and set https in the profile", http.StatusMethodNotAllowed)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "not a proxy request", http.StatusBadRequest)
+		return
+	}
+	proxy := p.lookup(r.URL.Host, r.URL.Hostname())
+	if proxy == nil {
+		http.Error(w, "no profile for host "+r.URL.Host, http.StatusForbidden)
+		return
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// serveProxy 本地的转发代理, 对旧客户端的http请求签名后转发到上游
+func serveProxy(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("proxy", stdout)
+	listen := fs.String("listen", "127.0.0.1:8081", "listen address, use it as http_proxy of the clients")
+	name := fs.String("profiles", "", "profiles file mapping upstream hosts to keys")
+	var af authFlags
+	af.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("profiles file is empty")
+	}
+	opts, err := af.options()
+	if err != nil {
+		return err
+	}
+	profiles, err := loadProfiles(*name)
+	if err != nil {
+		return err
+	}
+	p, err := newSigningProxy(profiles, af.headerNames(), opts)
+	if err != nil {
+		return err
+	}
+	log.Printf("aksk proxy listening on %s", *listen)
+	return http.ListenAndServe(*listen, p)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qingtao/aksk/v2/middleware"
+	"github.com/qingtao/aksk/v2/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningProxy(t *testing.T) {
+	getKey := func(ak string) (string, error) {
+		if ak == "123" {
+			return "456", nil
+		}
+		return "", nil
+	}
+	mw := middleware.New(middleware.Config{KeyGetter: getKey, RejectLegacy: true})
+	upstream := httptest.NewServer(mw.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	p, err := newSigningProxy(map[string]*profile{
+		u.Host:        {AccessKey: "123", SecretKey: "456", Version: "3", SignedHeaders: []string{"host"}},
+		"example.com": {AccessKey: "123", SecretKey: "abc", Version: "2"},
+	}, request.DefaultHeaderNames(), nil)
+	assert.NoError(t, err)
+	server := httptest.NewServer(p)
+	defer server.Close()
+	proxyURL, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Post(upstream.URL+"/api", "text/plain", strings.NewReader("helloworld"))
+	assert.NoError(t, err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "helloworld", string(b))
+
+	// 直接访问上游时没有签名
+	resp, err = http.Get(upstream.URL + "/api")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+	}{
+		{name: "FailedNoProfile", method: http.MethodGet, target: "http://other.example.com/", wantStatus: http.StatusForbidden},
+		{name: "FailedNotProxyRequest", method: http.MethodGet, target: "/api", wantStatus: http.StatusBadRequest},
+		{name: "FailedConnect", method: http.MethodConnect, target: "example.com:443", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, r)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestSigningProxyLookup(t *testing.T) {
+	p, err := newSigningProxy(map[string]*profile{
+		"api.example.com":      {AccessKey: "1", SecretKey: "1"},
+		"api.example.com:8080": {AccessKey: "2", SecretKey: "2"},
+		"*.example.org":        {AccessKey: "3", SecretKey: "3"},
+	}, request.DefaultHeaderNames(), nil)
+	assert.NoError(t, err)
+	tests := []struct {
+		name     string
+		hostport string
+		host     string
+		want     string
+	}{
+		{name: "OkHost", hostport: "API.example.com", host: "API.example.com", want: "api.example.com"},
+		{name: "OkHostPort", hostport: "api.example.com:8080", host: "api.example.com", want: "api.example.com:8080"},
+		{name: "OkOtherPort", hostport: "api.example.com:9090", host: "api.example.com", want: "api.example.com"},
+		{name: "OkWildcard", hostport: "a.b.example.org", host: "a.b.example.org", want: "*.example.org"},
+		{name: "OkNotFound", hostport: "example.org", host: "example.org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.lookup(tt.hostport, tt.host)
+			if tt.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Same(t, p.proxies[tt.want], got)
+		})
+	}
+}
+
+func TestServeProxy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, s string) string {
+		name = filepath.Join(dir, name)
+		os.WriteFile(name, []byte(s), 0o600)
+		return name
+	}
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "FailedNoProfiles", args: []string{"proxy"}},
+		{name: "FailedMissingFile", args: []string{"proxy", "-profiles", filepath.Join(dir, "missing.json")}},
+		{name: "FailedJSON", args: []string{"proxy", "-profiles", write("invalid.json", "{")}},
+		{name: "FailedEmpty", args: []string{"proxy", "-profiles", write("empty.json", "{}")}},
+		{name: "FailedNoKey", args: []string{"proxy", "-profiles", write("nokey.json", `{"example.com": {"access_key": "123"}}`)}},
+		{name: "FailedFormat", args: []string{"proxy", "-profiles", write("format.json", `{"example.com": {"access_key": "123", "secret_key": "456", "format": "query"}}`)}},
+		{name: "FailedVersion", args: []string{"proxy", "-profiles", write("version.json", `{"example.com": {"access_key": "123", "secret_key": "456", "version": "9"}}`)}},
+		{name: "FailedHash", args: []string{"proxy", "-hash", "md5", "-profiles", write("hash.json", `{"example.com": {"access_key": "123", "secret_key": "456"}}`)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			assert.Error(t, run(tt.args, nil, &stdout))
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/request"
+)
+
+// headerFlags 可以重复的-H参数
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(s string) error {
+	if !strings.Contains(s, ":") {
+		return fmt.Errorf("header %q invalid", s)
+	}
+	*h = append(*h, s)
+	return nil
+}
+
+// sign 对请求签名, 输出签名头部或者curl命令
+func sign(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("sign", stdout)
+	ak := fs.String("ak", "", "access key")
+	sk := fs.String("sk", "", "secret key")
+	method := fs.String("X", "", "request method, default GET, or POST if -d is set")
+	data := fs.String("d", "", "file containing the request body, - for stdin")
+	skipBody := fs.Bool("skip-body", false, "do not sign the request body")
+	unsignedPayload := fs.Bool("unsigned-payload", false, "declare UNSIGNED-PAYLOAD instead of the body hash")
+	curl := fs.Bool("curl", false, "print a curl command instead of the headers")
+	format := fs.String("format", "headers", "signature format: headers, authorization")
+	signedHeaders := fs.String("signed-headers", "", "semicolon separated headers to sign, e.g. host;content-type, requires -version 3")
+	bodyHashMode := fs.String("body-hash-mode", "", "decoded hashes the body after removing Content-Encoding, empty hashes the wire bytes")
+	canonicalization := fs.String("canonicalization", "", "body canonicalization before hashing: jcs for application/json bodies, form for form and multipart bodies")
+	version := fs.String("version", "", "signature scheme version, 3 also signs the signed header names with unambiguous delimiters, 2 hashes the exact body bytes, empty for the legacy scheme")
+	var headers headerFlags
+	fs.Var(&headers, "H", "extra request header, can be repeated")
+	var af authFlags
+	af.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("sign requires exactly one URL")
+	}
+	url := fs.Arg(0)
+	opts, err := af.options()
+	if err != nil {
+		return err
+	}
+	cfg := request.ModifierConfig{
+		AccessKey:        *ak,
+		SecretKey:        *sk,
+		SkipBody:         *skipBody,
+		HeaderNames:      af.headerNames(),
+		Version:          request.Version(*version),
+		BodyHashMode:     request.BodyHashMode(*bodyHashMode),
+		Canonicalization: request.Canonicalization(*canonicalization),
+	}
+	switch *format {
+	case "headers":
+		cfg.Format = request.FormatHeaders
+	case "authorization":
+		cfg.Format = request.FormatAuthorization
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if *signedHeaders != "" {
+		cfg.SignedHeaders = strings.Split(*signedHeaders, ";")
+	}
+	modifier, err := request.NewModifier(cfg, opts...)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	switch *data {
+	case "":
+	case "-":
+		body, err = ioutil.ReadAll(stdin)
+	default:
+		body, err = ioutil.ReadFile(*data)
+	}
+	if err != nil {
+		return err
+	}
+	if *method == "" {
+		*method = http.MethodGet
+		if *data != "" {
+			*method = http.MethodPost
+		}
+	}
+	ctx := context.Background()
+	if *unsignedPayload {
+		ctx = request.WithUnsignedPayload(ctx)
+	}
+	req, err := http.NewRequestWithContext(ctx, *method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for _, h := range headers {
+		kv := strings.SplitN(h, ":", 2)
+		req.Header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	if err := modifier.ModifyRequest(req); err != nil {
+		return err
+	}
+	if !*curl {
+		writeHeaders(stdout, req.Header)
+		return nil
+	}
+	fmt.Fprintf(stdout, "curl -X %s", *method)
+	for _, k := range sortedKeys(req.Header) {
+		for _, v := range req.Header[k] {
+			fmt.Fprintf(stdout, " \\\n  -H %s", shellQuote(k+": "+v))
+		}
+	}
+	switch *data {
+	case "":
+	case "-":
+		// 标准输入已经被读取, 只能内联body
+		fmt.Fprintf(stdout, " \\\n  --data-binary %s", shellQuote(string(body)))
+	default:
+		fmt.Fprintf(stdout, " \\\n  --data-binary %s", shellQuote("@"+*data))
+	}
+	fmt.Fprintf(stdout, " \\\n  %s\n", shellQuote(url))
+	return nil
+}
+
+func sortedKeys(h http.Header) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeHeaders 按名称排序输出头部
+func writeHeaders(w io.Writer, h http.Header) {
+	for _, k := range sortedKeys(h) {
+		for _, v := range h[k] {
+			fmt.Fprintf(w, "%s: %s\n", k, v)
+		}
+	}
+}
+
+// shellQuote 使用单引号转义shell参数
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
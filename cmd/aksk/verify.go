@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/qingtao/aksk/v2/request"
+)
+
+// readRequest 从r读取原始的http请求
+func readRequest(r io.Reader) (*http.Request, error) {
+	req, err := http.ReadRequest(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("read request error %w", err)
+	}
+	return req, nil
+}
+
+// verify 从标准输入读取原始的http请求, 使用secret key校验签名
+func verify(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("verify", stdout)
+	sk := fs.String("sk", "", "secret key")
+	skipBody := fs.Bool("skip-body", false, "do not verify the request body")
+	rejectLegacy := fs.Bool("reject-legacy", false, "reject requests using the legacy signature scheme")
+	minVersion := fs.String("min-version", "", "minimum signature scheme version accepted, e.g. 3")
+	allowUnsigned := fs.Bool("allow-unsigned-payload", false, "accept requests declaring UNSIGNED-PAYLOAD")
+	var af authFlags
+	af.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *sk == "" {
+		return errors.New("secret key is empty")
+	}
+	opts, err := af.options()
+	if err != nil {
+		return err
+	}
+	getter := func(ak string) (string, error) {
+		return *sk, nil
+	}
+	cfg := request.ValidatorConfig{
+		KeyGetter:    getter,
+		SkipBody:     *skipBody,
+		HeaderNames:  af.headerNames(),
+		RejectLegacy: *rejectLegacy,
+		MinVersion:   request.Version(*minVersion),
+	}
+	if *allowUnsigned {
+		cfg.AllowUnsignedPayload = func(*http.Request) bool { return true }
+	}
+	validator, err := request.NewValidator(cfg, opts...)
+	if err != nil {
+		return err
+	}
+	req, err := readRequest(stdin)
+	if err != nil {
+		return err
+	}
+	if err := validator.Validate(req); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "OK")
+	return nil
+}
+
+// explain 从标准输入读取原始的http请求, 输出待签名的字符串和计算的中间值
+func explain(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("explain", stdout)
+	sk := fs.String("sk", "", "secret key")
+	var af authFlags
+	af.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *sk == "" {
+		return errors.New("secret key is empty")
+	}
+	opts, err := af.options()
+	if err != nil {
+		return err
+	}
+	req, err := readRequest(stdin)
+	if err != nil {
+		return err
+	}
+	e, err := request.Explain(req, *sk, af.headerNames(), opts...)
+	if err != nil {
+		return err
+	}
+	if e.Version != request.VersionLegacy {
+		fmt.Fprintf(stdout, "version:             %q\n", e.Version)
+	}
+	if e.Algorithm != "" {
+		fmt.Fprintf(stdout, "algorithm:           %q\n", e.Algorithm)
+	}
+	fmt.Fprintf(stdout, "access key:          %q\n", e.AccessKey)
+	fmt.Fprintf(stdout, "timestamp:           %q\n", e.Timestamp)
+	fmt.Fprintf(stdout, "timestamp check:     %s\n", result(core.New(opts...).ParseTimestamp(e.Timestamp)))
+	if e.Scope != "" {
+		fmt.Fprintf(stdout, "scope:               %q\n", e.Scope)
+	}
+	if len(e.SignedHeaders) > 0 {
+		fmt.Fprintf(stdout, "signed headers:      %q\n", strings.Join(e.SignedHeaders, ";"))
+	}
+	fmt.Fprintf(stdout, "body hash (header):  %q\n", e.BodyHash)
+	fmt.Fprintf(stdout, "body hash (body):    %q\n", e.ComputedBodyHash)
+	fmt.Fprintf(stdout, "canonical string:    %q\n", e.CanonicalString)
+	fmt.Fprintf(stdout, "signing key:         %q\n", e.SigningKey)
+	fmt.Fprintf(stdout, "signature (header):  %q\n", e.Signature)
+	fmt.Fprintf(stdout, "signature (secret):  %q\n", e.ComputedSignature)
+	return nil
+}
+
+func result(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultEncoding 算法名称中省略编码格式时使用的编码
+const DefaultEncoding = "base64"
+
+var registry = struct {
+	sync.RWMutex
+	hashes   map[string]hashEntry
+	encoders map[string]Encoder
+}{
+	hashes: builtinHashes(),
+	encoders: map[string]Encoder{
+		"base64":    &Base64Encoder{},
+		"base64url": &Base64URLEncoder{},
+		"base64raw": &RawBase64Encoder{},
+		"base32":    &Base32Encoder{},
+		"hex":       &HexEncoder{},
+		"hexupper":  &UpperHexEncoder{},
+	},
+}
+
+// normalizeName 名称不区分大小写
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// RegisterEncoder 注册名称为name的编码格式, 已经存在时覆盖
+func RegisterEncoder(name string, enc Encoder) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.encoders[normalizeName(name)] = enc
+}
+
+// LookupEncoder 查询名称为name的编码格式
+func LookupEncoder(name string) (Encoder, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	enc, ok := registry.encoders[normalizeName(name)]
+	if !ok {
+		return nil, fmt.Errorf("encoder %q not found", name)
+	}
+	return enc, nil
+}
+
+// Encoders 返回已注册的编码格式名称
+func Encoders() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return sortedNames(registry.encoders)
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Algorithm 命名的签名算法, 由hash算法和编码格式组成
+type Algorithm struct {
+	// 名称, 格式为: hash算法/编码格式, 例如: sha256/base64
+	Name    string
+	Hash    HashFunc
+	Encoder Encoder
+}
+
+// LookupAlgorithm 查询名称为name的签名算法, 名称的格式为: hash算法[/编码格式], 省略编码格式时使用base64;
+// hash算法的强度低于MinHashStrength时返回错误
+func LookupAlgorithm(name string) (*Algorithm, error) {
+	return lookupAlgorithm(name, LookupHash)
+}
+
+// LookupLegacyAlgorithm 查询名称为name的签名算法, 不检查hash算法的强度, 只用于兼容旧的客户端
+func LookupLegacyAlgorithm(name string) (*Algorithm, error) {
+	return lookupAlgorithm(name, LookupLegacyHash)
+}
+
+func lookupAlgorithm(name string, lookupHash func(string) (HashFunc, error)) (*Algorithm, error) {
+	name = normalizeName(name)
+	hashName, encName, ok := strings.Cut(name, "/")
+	if !ok {
+		encName = DefaultEncoding
+	}
+	h, err := lookupHash(hashName)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := LookupEncoder(encName)
+	if err != nil {
+		return nil, err
+	}
+	return &Algorithm{
+		Name:    hashName + "/" + encName,
+		Hash:    h,
+		Encoder: enc,
+	}, nil
+}
+
+// WithAlgorithm 使用命名的签名算法, 覆盖之前的WithHash和WithEncoder; 客户端在请求中声明算法的名称, 并且算法名称参与签名
+func WithAlgorithm(alg *Algorithm) Option {
+	return func(o *Options) {
+		if alg != nil {
+			o.Algorithm = alg.Name
+			o.Hash = alg.Hash
+			o.Encoder = alg.Encoder
+		}
+	}
+}
+
+// WithAllowedAlgorithms 服务端允许客户端声明的签名算法, 为空时只允许WithAlgorithm指定的算法;
+// 强度低于MinHashStrength的算法即使在列表中也会被拒绝, 需要使用WithLegacyAlgorithms
+func WithAllowedAlgorithms(names ...string) Option {
+	return func(o *Options) {
+		o.AllowedAlgorithms = names
+	}
+}
+
+// WithLegacyAlgorithms 服务端明确允许的弱签名算法, 例如: sha1/base64, 只用于兼容旧的客户端
+func WithLegacyAlgorithms(names ...string) Option {
+	return func(o *Options) {
+		o.LegacyAlgorithms = names
+	}
+}
+
+// Algorithm 返回签名算法的名称, 没有使用命名的签名算法时返回空字符串
+func (s *Auth) Algorithm() string {
+	return s.algorithm
+}
+
+// Negotiate 检查客户端声明的签名算法name是否允许, 返回使用该算法的认证对象;
+// name为空时表示客户端没有声明算法, 返回s本身
+func (s *Auth) Negotiate(name string) (*Auth, error) {
+	if name == "" {
+		return s, nil
+	}
+	alg, err := LookupLegacyAlgorithm(name)
+	if err != nil {
+		return nil, Errorf("algorithm not allowed", "algorithm %s: %w", Quote(name), err)
+	}
+	if alg.Name != name {
+		return nil, Errorf("algorithm not allowed", "algorithm %s is not canonical", Quote(name))
+	}
+	// 弱算法只能通过WithLegacyAlgorithms允许
+	allowed := containsAlgorithm(s.legacy, alg.Name, LookupLegacyAlgorithm)
+	if !allowed {
+		if _, err := LookupAlgorithm(name); err != nil {
+			return nil, Errorf("algorithm not allowed", "algorithm %s: %w", Quote(name), err)
+		}
+		allowed = alg.Name == s.algorithm || containsAlgorithm(s.allowed, alg.Name, LookupAlgorithm)
+	}
+	if !allowed {
+		return nil, Errorf("algorithm not allowed", "algorithm %s not allowed", Quote(name))
+	}
+	a := *s
+	a.algorithm = alg.Name
+	a.h = alg.Hash
+	a.enc = alg.Encoder
+	if s.lenient {
+		a.enc = Lenient(a.enc)
+	}
+	return &a, nil
+}
+
+// containsAlgorithm names中是否有名称为name的算法
+func containsAlgorithm(names []string, name string, lookup func(string) (*Algorithm, error)) bool {
+	for _, v := range names {
+		if a, err := lookup(v); err == nil && a.Name == name {
+			return true
+		}
+	}
+	return false
+}
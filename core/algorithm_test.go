@@ -0,0 +1,90 @@
+package core
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupAlgorithm(t *testing.T) {
+	tests := []struct {
+		name     string
+		alg      string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "Ok", alg: "sha256/base64", wantName: "sha256/base64"},
+		{name: "OkDefaultEncoding", alg: "SHA512", wantName: "sha512/base64"},
+		{name: "OkHex", alg: "sha384/hex", wantName: "sha384/hex"},
+		{name: "OkSHA512_256", alg: "sha512-256", wantName: "sha512-256/base64"},
+		{name: "OkSHA3", alg: "sha3-256/base64url", wantName: "sha3-256/base64url"},
+		{name: "OkBLAKE2b", alg: "blake2b-512/hex", wantName: "blake2b-512/hex"},
+		{name: "FailedWeakSHA1", alg: "sha1/base64", wantErr: true},
+		{name: "FailedWeakMD5", alg: "md5", wantErr: true},
+		{name: "FailedHash", alg: "md4/base64", wantErr: true},
+		{name: "FailedEncoder", alg: "sha256/base58", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LookupAlgorithm(tt.alg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LookupAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.wantName, got.Name)
+			}
+		})
+	}
+}
+
+func TestRegisterHash(t *testing.T) {
+	RegisterHash("Test-SHA512", sha512.New)
+	h, err := LookupHash("test-sha512")
+	assert.NoError(t, err)
+	assert.Equal(t, sha512.Size, h().Size())
+	assert.Contains(t, Hashes(), "test-sha512")
+	RegisterEncoder("test-hex", &HexEncoder{})
+	assert.Contains(t, Encoders(), "test-hex")
+}
+
+func TestAuth_Negotiate(t *testing.T) {
+	sha256Alg, _ := LookupAlgorithm("sha256")
+	tests := []struct {
+		name     string
+		s        *Auth
+		alg      string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "OkEmpty", s: New(), alg: "", wantName: ""},
+		{name: "OkSame", s: New(WithAlgorithm(sha256Alg)), alg: "sha256/base64", wantName: "sha256/base64"},
+		{name: "OkAllowed", s: New(WithAlgorithm(sha256Alg), WithAllowedAlgorithms("sha512")), alg: "sha512/base64", wantName: "sha512/base64"},
+		{name: "FailedNotAllowed", s: New(WithAlgorithm(sha256Alg), WithAllowedAlgorithms("sha512")), alg: "sha1/base64", wantErr: true},
+		{name: "FailedNoAlgorithm", s: New(), alg: "sha256/base64", wantErr: true},
+		{name: "FailedNotCanonical", s: New(WithAlgorithm(sha256Alg)), alg: "SHA256", wantErr: true},
+		{name: "FailedUnknown", s: New(WithAllowedAlgorithms("md4")), alg: "md4/base64", wantErr: true},
+		{name: "FailedWeak", s: New(WithAllowedAlgorithms("sha1")), alg: "sha1/base64", wantErr: true},
+		{name: "OkLegacy", s: New(WithLegacyAlgorithms("sha1")), alg: "sha1/base64", wantName: "sha1/base64"},
+		{name: "OkLegacyMD5", s: New(WithLegacyAlgorithms("md5/hex")), alg: "md5/hex", wantName: "md5/hex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.s.Negotiate(tt.alg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Auth.Negotiate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.wantName, got.Algorithm())
+			}
+		})
+	}
+	// 协商不修改原来的对象
+	s := New(WithAllowedAlgorithms("sha512/hex"))
+	a, _ := s.Negotiate("sha512/hex")
+	assert.Equal(t, sha512.Size, len(a.Sum(nil)))
+	assert.Equal(t, sha256.Size, len(s.Sum(nil)))
+}
@@ -2,13 +2,11 @@
 package core
 
 import (
-	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"hash"
 	"sort"
 	"strconv"
@@ -61,6 +59,15 @@ type Auth struct {
 	enc Encoder
 	h   HashFunc
 	d   time.Duration
+	// 签名密钥的作用范围
+	region  string
+	service string
+	// 签名算法的名称和允许客户端声明的签名算法
+	algorithm string
+	allowed   []string
+	legacy    []string
+	// 解码时同时接受有填充和没有填充的输入
+	lenient bool
 }
 
 // Options 选项
@@ -71,6 +78,18 @@ type Options struct {
 	Hash HashFunc
 	// 检查时间戳时,允许的误差
 	AcceptableSkew time.Duration
+	// 签名密钥作用范围的区域, 和Service同时非空时有效
+	Region string
+	// 签名密钥作用范围的服务, 和Region同时非空时有效
+	Service string
+	// 命名的签名算法, 非空时客户端在请求中声明算法
+	Algorithm string
+	// 服务端允许客户端声明的签名算法
+	AllowedAlgorithms []string
+	// 服务端明确允许的弱签名算法
+	LegacyAlgorithms []string
+	// 解码时同时接受有填充和没有填充的输入
+	LenientDecoding bool
 }
 
 func defaultOptions() *Options {
@@ -124,28 +143,38 @@ func WithAcceptableSkew(d time.Duration) Option {
 // New 新建认证对象,默认时: 字符串编码使用base64.StdEncoding, hash算法使用sha256,允许的时间戳误差为60秒
 func New(opts ...Option) *Auth {
 	o := mergeOptions(opts...)
+	enc := o.Encoder
+	if o.LenientDecoding {
+		enc = Lenient(enc)
+	}
 	return &Auth{
-		enc: o.Encoder,
-		h:   o.Hash,
-		d:   o.AcceptableSkew,
+		enc:       enc,
+		h:         o.Hash,
+		d:         o.AcceptableSkew,
+		region:    o.Region,
+		service:   o.Service,
+		algorithm: o.Algorithm,
+		allowed:   o.AllowedAlgorithms,
+		legacy:    o.LegacyAlgorithms,
+		lenient:   o.LenientDecoding,
 	}
 }
 
 // ParseTimestamp 解析时间戳,如果时间戳不是有效的整数,或者超过允许的时间误差,则认为是无效的
 func (s *Auth) ParseTimestamp(ts string) error {
 	if ts == "" {
-		return fmt.Errorf("timetamp is empty")
+		return errors.New("timestamp is empty")
 	}
 	n, err := strconv.ParseInt(ts, 10, 64)
 	if err != nil {
-		return fmt.Errorf("timestamp %s invalid: %w", ts, err)
+		return Errorf("timestamp invalid", "timestamp %s invalid: %w", Quote(ts), err)
 	}
 	t := time.Unix(n, 0)
 	d := time.Since(t)
 	if d > s.d {
-		return fmt.Errorf("timestamp %s expired", ts)
+		return Errorf("timestamp expired", "timestamp %s expired", Quote(ts))
 	} else if d < -s.d {
-		return fmt.Errorf("timestamp %s invalid", ts)
+		return Errorf("timestamp invalid", "timestamp %s is in the future", Quote(ts))
 	}
 	return nil
 }
@@ -157,17 +186,28 @@ func (s *Auth) Sum(b []byte) []byte {
 	return h.Sum(nil)
 }
 
+// NewHash 返回新的hash.Hash, 用于流式计算hash值
+func (s *Auth) NewHash() hash.Hash {
+	return s.h()
+}
+
 // EncodeToString 编码
 func (s *Auth) EncodeToString(b []byte) string {
 	return s.enc.EncodeToString(b)
 }
 
+// CanonicalString 返回待签名的字符串: 将elems按字符串排序后拼接, 不修改elems
+func (s *Auth) CanonicalString(elems ...string) string {
+	a := make([]string, len(elems))
+	copy(a, elems)
+	sort.Strings(a)
+	return strings.Join(a, "")
+}
+
 // Hmac 计算hmac值
 func (s *Auth) Hmac(key []byte, elems ...string) []byte {
 	h := hmac.New(s.h, key)
-	sort.Strings(elems)
-	str := strings.Join(elems, "")
-	h.Write([]byte(str))
+	h.Write([]byte(s.CanonicalString(elems...)))
 	return h.Sum(nil)
 }
 
@@ -178,26 +218,37 @@ func (s *Auth) ValidBody(b []byte, str string) error {
 		return nil
 	}
 	if str == "" {
-		return fmt.Errorf("the mac of body is empty")
+		return errors.New("the mac of body is empty")
 	}
+	return s.ValidBodyHash(b, str)
+}
+
+// ValidBodyHash 检查b的hash值和编码的hash值str是否一致, b为空时同样计算hash值
+func (s *Auth) ValidBodyHash(b []byte, str string) error {
 	mac, err := s.enc.DecodeString(str)
 	if err != nil {
-		return fmt.Errorf("body invalid")
+		return Errorf("body invalid", "the mac of body %s invalid: %w", Quote(str), err)
 	}
-	if ok := bytes.Equal(mac, s.Sum(b)); !ok {
-		return fmt.Errorf("body invalid")
+	// 使用常量时间的比较, 避免通过响应时间推测hash值
+	if ok := hmac.Equal(mac, s.Sum(b)); !ok {
+		return errors.New("body invalid")
 	}
 	return nil
 }
 
 // ValidSignature 校验头部签名
 func (s *Auth) ValidSignature(sk, sign string, elems ...string) error {
+	return s.ValidSignatureKey([]byte(sk), sign, elems...)
+}
+
+// ValidSignatureKey 使用签名密钥key校验头部签名, key可以是私有密钥或者派生的签名密钥
+func (s *Auth) ValidSignatureKey(key []byte, sign string, elems ...string) error {
 	// 解码签名,得道原始的字节切片
 	mac, err := s.enc.DecodeString(sign)
 	if err != nil {
-		return fmt.Errorf("signature %s invalid", sign)
+		return Errorf("signature invalid", "signature %s invalid: %w", Quote(sign), err)
 	}
-	if ok := hmac.Equal(mac, s.Hmac([]byte(sk), elems...)); !ok {
+	if ok := hmac.Equal(mac, s.Hmac(key, elems...)); !ok {
 		return errors.New("signature invalid")
 	}
 	return nil
@@ -411,3 +411,11 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestAuth_CanonicalString(t *testing.T) {
+	s := New()
+	elems := []string{"b", "c", "a"}
+	assert.Equal(t, "abc", s.CanonicalString(elems...))
+	assert.Equal(t, []string{"b", "c", "a"}, elems)
+	assert.Equal(t, s.Hmac([]byte("123"), "a", "b", "c"), s.Hmac([]byte("123"), elems...))
+}
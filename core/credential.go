@@ -0,0 +1,183 @@
+package core
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/big"
+	"strings"
+)
+
+// AlphabetBase62 默认的密钥字符集
+const AlphabetBase62 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// Credential 访问密钥和私有密钥
+type Credential struct {
+	AccessKey string
+	SecretKey string
+}
+
+// CredentialOptions 生成密钥的选项
+type CredentialOptions struct {
+	// 访问密钥随机部分的长度, 不包含前缀和校验码
+	AccessKeyLength int
+	// 私有密钥的长度
+	SecretKeyLength int
+	// 密钥使用的字符集
+	Alphabet string
+	// 访问密钥的前缀, 例如: AKID, 便于识别和扫描泄露的密钥
+	Prefix string
+	// 访问密钥是否附加校验码, 校验码可以离线校验访问密钥
+	Checksum bool
+}
+
+func defaultCredentialOptions() *CredentialOptions {
+	return &CredentialOptions{
+		AccessKeyLength: 20,
+		SecretKeyLength: 40,
+		Alphabet:        AlphabetBase62,
+		Checksum:        true,
+	}
+}
+
+// CredentialOption 生成密钥的选项
+type CredentialOption func(*CredentialOptions)
+
+func mergeCredentialOptions(opts ...CredentialOption) (*CredentialOptions, error) {
+	o := defaultCredentialOptions()
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	if o.AccessKeyLength <= 0 || o.SecretKeyLength <= 0 {
+		return nil, errors.New("key length invalid")
+	}
+	if len(o.Alphabet) < 2 {
+		return nil, errors.New("alphabet is too short")
+	}
+	for i := 0; i < len(o.Alphabet); i++ {
+		if o.Alphabet[i] >= 0x80 {
+			return nil, errors.New("alphabet must be ASCII")
+		}
+		if strings.IndexByte(o.Alphabet[i+1:], o.Alphabet[i]) >= 0 {
+			return nil, fmt.Errorf("alphabet has duplicate character %q", o.Alphabet[i])
+		}
+	}
+	return o, nil
+}
+
+// WithKeyLength 访问密钥随机部分和私有密钥的长度
+func WithKeyLength(accessKey, secretKey int) CredentialOption {
+	return func(o *CredentialOptions) {
+		o.AccessKeyLength = accessKey
+		o.SecretKeyLength = secretKey
+	}
+}
+
+// WithAlphabet 使用指定的字符集
+func WithAlphabet(alphabet string) CredentialOption {
+	return func(o *CredentialOptions) {
+		if alphabet != "" {
+			o.Alphabet = alphabet
+		}
+	}
+}
+
+// WithPrefix 访问密钥的前缀
+func WithPrefix(prefix string) CredentialOption {
+	return func(o *CredentialOptions) {
+		o.Prefix = prefix
+	}
+}
+
+// WithChecksum 访问密钥是否附加校验码
+func WithChecksum(checksum bool) CredentialOption {
+	return func(o *CredentialOptions) {
+		o.Checksum = checksum
+	}
+}
+
+// GenerateCredential 使用crypto/rand生成随机的密钥,
+// 默认时: 字符集为base62, 访问密钥长度为20并附加6位校验码, 私有密钥长度为40
+func GenerateCredential(opts ...CredentialOption) (*Credential, error) {
+	o, err := mergeCredentialOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	ak, err := randomString(o.Alphabet, o.AccessKeyLength)
+	if err != nil {
+		return nil, err
+	}
+	ak = o.Prefix + ak
+	if o.Checksum {
+		ak += checksum(o.Alphabet, ak)
+	}
+	sk, err := randomString(o.Alphabet, o.SecretKeyLength)
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{AccessKey: ak, SecretKey: sk}, nil
+}
+
+// ValidAccessKey 离线校验访问密钥的前缀, 长度, 字符集和校验码, opts必须和生成时一致
+func ValidAccessKey(ak string, opts ...CredentialOption) error {
+	o, err := mergeCredentialOptions(opts...)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(ak, o.Prefix) {
+		return errors.New("access key prefix invalid")
+	}
+	var sum string
+	if o.Checksum {
+		sum = checksum(o.Alphabet, "")
+	}
+	if len(ak) != len(o.Prefix)+o.AccessKeyLength+len(sum) {
+		return errors.New("access key length invalid")
+	}
+	for i := len(o.Prefix); i < len(ak); i++ {
+		if strings.IndexByte(o.Alphabet, ak[i]) < 0 {
+			return errors.New("access key has invalid character")
+		}
+	}
+	if o.Checksum {
+		n := len(ak) - len(sum)
+		if checksum(o.Alphabet, ak[:n]) != ak[n:] {
+			return errors.New("access key checksum invalid")
+		}
+	}
+	return nil
+}
+
+// randomString 生成长度为n的随机字符串, 每个字符均匀的取自alphabet
+func randomString(alphabet string, n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range b {
+		k, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[k.Int64()]
+	}
+	return string(b), nil
+}
+
+// checksum 计算s的crc32值, 按alphabet编码为固定长度的字符串
+func checksum(alphabet, s string) string {
+	base := uint64(len(alphabet))
+	// 编码crc32需要的位数
+	n := 0
+	for max := uint64(1) << 32; max > 1; max = (max + base - 1) / base {
+		n++
+	}
+	v := uint64(crc32.ChecksumIEEE([]byte(s)))
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = alphabet[v%base]
+		v /= base
+	}
+	return string(b)
+}
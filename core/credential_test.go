@@ -0,0 +1,100 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateCredential(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []CredentialOption
+		akLen   int
+		skLen   int
+		wantErr bool
+	}{
+		{
+			name:  "Default",
+			akLen: 26,
+			skLen: 40,
+		},
+		{
+			name:  "Prefix",
+			opts:  []CredentialOption{WithPrefix("AKID"), WithKeyLength(16, 32)},
+			akLen: 26,
+			skLen: 32,
+		},
+		{
+			name:  "NoChecksum",
+			opts:  []CredentialOption{WithChecksum(false)},
+			akLen: 20,
+			skLen: 40,
+		},
+		{
+			name:  "HexAlphabet",
+			opts:  []CredentialOption{WithAlphabet("0123456789abcdef")},
+			akLen: 28,
+			skLen: 40,
+		},
+		{
+			name:    "FailedLength",
+			opts:    []CredentialOption{WithKeyLength(0, 32)},
+			wantErr: true,
+		},
+		{
+			name:    "FailedAlphabet",
+			opts:    []CredentialOption{WithAlphabet("a")},
+			wantErr: true,
+		},
+		{
+			name:    "FailedDuplicateAlphabet",
+			opts:    []CredentialOption{WithAlphabet("abca")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := GenerateCredential(tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateCredential() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Len(t, c.AccessKey, tt.akLen)
+			assert.Len(t, c.SecretKey, tt.skLen)
+			assert.NoError(t, ValidAccessKey(c.AccessKey, tt.opts...))
+		})
+	}
+}
+
+func TestValidAccessKey(t *testing.T) {
+	c, err := GenerateCredential(WithPrefix("AKID"))
+	if err != nil {
+		t.Fatalf("GenerateCredential() error = %v", err)
+	}
+	// 修改随机部分的一个字符
+	b := []byte(c.AccessKey)
+	b[4] = strings.Replace(AlphabetBase62, string(b[4]), "", 1)[0]
+	tests := []struct {
+		name    string
+		ak      string
+		wantErr bool
+	}{
+		{name: "Ok", ak: c.AccessKey, wantErr: false},
+		{name: "FailedPrefix", ak: "AKIX" + c.AccessKey[4:], wantErr: true},
+		{name: "FailedLength", ak: c.AccessKey[:len(c.AccessKey)-1], wantErr: true},
+		{name: "FailedCharacter", ak: c.AccessKey[:len(c.AccessKey)-1] + "-", wantErr: true},
+		{name: "FailedChecksum", ak: string(b), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidAccessKey(tt.ak, WithPrefix("AKID")); (err != nil) != tt.wantErr {
+				t.Errorf("ValidAccessKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// derivedSecretInfo 派生私有密钥时使用的上下文, 避免和主密钥的其他用途冲突
+const derivedSecretInfo = "aksk derived secret key\x00"
+
+// KeyDeriver 使用主密钥派生私有密钥, 服务端不需要存储每个客户端的私有密钥.
+//
+// 访问密钥的格式为: 前缀 + 主密钥ID + "." + 随机字符串 + 校验码,
+// 私有密钥为 base64url(hmac_sha256(主密钥, 访问密钥)).
+// 轮换主密钥时, 新增主密钥并使用新的ID签发, 旧的主密钥保留到其签发的密钥全部失效.
+type KeyDeriver struct {
+	current string
+	masters map[string][]byte
+	opts    *CredentialOptions
+}
+
+// NewKeyDeriver 新建派生密钥的对象, current为签发新密钥时使用的主密钥ID, masters为主密钥ID到主密钥的映射,
+// opts为生成访问密钥的选项
+func NewKeyDeriver(current string, masters map[string][]byte, opts ...CredentialOption) (*KeyDeriver, error) {
+	o, err := mergeCredentialOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := masters[current]; !ok {
+		return nil, fmt.Errorf("master key %q not found", current)
+	}
+	m := make(map[string][]byte, len(masters))
+	for id, key := range masters {
+		if id == "" || strings.Contains(id, ".") {
+			return nil, fmt.Errorf("master key id %q invalid", id)
+		}
+		if len(key) < 16 {
+			return nil, fmt.Errorf("master key %q is too short", id)
+		}
+		m[id] = append([]byte(nil), key...)
+	}
+	return &KeyDeriver{
+		current: current,
+		masters: m,
+		opts:    o,
+	}, nil
+}
+
+// Issue 使用当前的主密钥签发新的密钥
+func (d *KeyDeriver) Issue() (*Credential, error) {
+	c, err := GenerateCredential(d.credentialOptions(d.current)...)
+	if err != nil {
+		return nil, err
+	}
+	c.SecretKey = deriveSecret(d.masters[d.current], c.AccessKey)
+	return c, nil
+}
+
+// SecretKey 从访问密钥派生私有密钥, 可以作为KeyGetter使用
+func (d *KeyDeriver) SecretKey(ak string) (string, error) {
+	s := strings.TrimPrefix(ak, d.opts.Prefix)
+	if len(s) == len(ak) && d.opts.Prefix != "" {
+		return "", errors.New("access key prefix invalid")
+	}
+	i := strings.IndexByte(s, '.')
+	if i <= 0 {
+		return "", errors.New("access key has no master key id")
+	}
+	id := s[:i]
+	master, ok := d.masters[id]
+	if !ok {
+		return "", fmt.Errorf("master key %q not found", id)
+	}
+	if err := ValidAccessKey(ak, d.credentialOptions(id)...); err != nil {
+		return "", err
+	}
+	return deriveSecret(master, ak), nil
+}
+
+// credentialOptions 返回使用主密钥id签发访问密钥的选项
+func (d *KeyDeriver) credentialOptions(id string) []CredentialOption {
+	return []CredentialOption{
+		WithKeyLength(d.opts.AccessKeyLength, d.opts.SecretKeyLength),
+		WithAlphabet(d.opts.Alphabet),
+		WithPrefix(d.opts.Prefix + id + "."),
+		WithChecksum(d.opts.Checksum),
+	}
+}
+
+// deriveSecret 使用主密钥派生访问密钥ak的私有密钥
+func deriveSecret(master []byte, ak string) string {
+	h := hmac.New(sha256.New, master)
+	h.Write([]byte(derivedSecretInfo))
+	h.Write([]byte(ak))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyDeriver(t *testing.T) {
+	master := []byte("0123456789abcdef0123456789abcdef")
+	tests := []struct {
+		name    string
+		current string
+		masters map[string][]byte
+		wantErr bool
+	}{
+		{name: "Ok", current: "k1", masters: map[string][]byte{"k1": master}},
+		{name: "FailedCurrent", current: "k2", masters: map[string][]byte{"k1": master}, wantErr: true},
+		{name: "FailedID", current: "k.1", masters: map[string][]byte{"k.1": master}, wantErr: true},
+		{name: "FailedShortKey", current: "k1", masters: map[string][]byte{"k1": []byte("123")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyDeriver(tt.current, tt.masters); (err != nil) != tt.wantErr {
+				t.Errorf("NewKeyDeriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyDeriver_SecretKey(t *testing.T) {
+	masters := map[string][]byte{
+		"k1": []byte("0123456789abcdef0123456789abcdef"),
+		"k2": []byte("fedcba9876543210fedcba9876543210"),
+	}
+	old, _ := NewKeyDeriver("k1", masters, WithPrefix("AKID"))
+	c1, err := old.Issue()
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	assert.True(t, strings.HasPrefix(c1.AccessKey, "AKIDk1."))
+	// 轮换主密钥后, 旧的主密钥签发的密钥仍然有效
+	d, _ := NewKeyDeriver("k2", masters, WithPrefix("AKID"))
+	c2, _ := d.Issue()
+	assert.True(t, strings.HasPrefix(c2.AccessKey, "AKIDk2."))
+
+	tests := []struct {
+		name    string
+		ak      string
+		want    string
+		wantErr bool
+	}{
+		{name: "Ok", ak: c2.AccessKey, want: c2.SecretKey},
+		{name: "OkRotated", ak: c1.AccessKey, want: c1.SecretKey},
+		{name: "FailedPrefix", ak: strings.TrimPrefix(c2.AccessKey, "AKID"), wantErr: true},
+		{name: "FailedNoID", ak: "AKID" + strings.Repeat("a", 26), wantErr: true},
+		{name: "FailedUnknownID", ak: strings.Replace(c2.AccessKey, "k2.", "k3.", 1), wantErr: true},
+		{name: "FailedChecksum", ak: strings.Replace(c2.AccessKey, "k2.", "k1.", 1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var getter KeyGetter = d.SecretKey
+			got, err := getter(tt.ak)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SecretKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
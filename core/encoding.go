@@ -0,0 +1,96 @@
+package core
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Base64URLEncoder URL安全并且没有填充的base64编码格式, 适用于查询字符串
+type Base64URLEncoder struct{}
+
+// EncodeToString 编码为base64url字符串
+func (enc *Base64URLEncoder) EncodeToString(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeString 解码给定的base64url字符串
+func (enc *Base64URLEncoder) DecodeString(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// RawBase64Encoder 没有填充的标准base64编码格式
+type RawBase64Encoder struct{}
+
+// EncodeToString 编码为没有填充的base64字符串
+func (enc *RawBase64Encoder) EncodeToString(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+// DecodeString 解码给定的没有填充的base64字符串
+func (enc *RawBase64Encoder) DecodeString(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// Base32Encoder 标准base32编码格式
+type Base32Encoder struct{}
+
+// EncodeToString 编码为base32字符串
+func (enc *Base32Encoder) EncodeToString(b []byte) string {
+	return base32.StdEncoding.EncodeToString(b)
+}
+
+// DecodeString 解码给定的base32字符串
+func (enc *Base32Encoder) DecodeString(s string) ([]byte, error) {
+	return base32.StdEncoding.DecodeString(s)
+}
+
+// UpperHexEncoder 大写的16进制编码格式, 解码时不区分大小写
+type UpperHexEncoder struct{}
+
+// EncodeToString 编码为大写的16进制字符串
+func (enc *UpperHexEncoder) EncodeToString(b []byte) string {
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// DecodeString 解码给定的16进制字符串
+func (enc *UpperHexEncoder) DecodeString(s string) ([]byte, error) {
+	return hex.DecodeString(s)
+}
+
+// lenientEncoder 解码时同时接受有填充和没有填充的字符串
+type lenientEncoder struct {
+	Encoder
+}
+
+// Lenient 返回解码时同时接受有填充和没有填充的输入的编码格式, 编码的结果与enc一致
+func Lenient(enc Encoder) Encoder {
+	if _, ok := enc.(*lenientEncoder); ok || enc == nil {
+		return enc
+	}
+	return &lenientEncoder{Encoder: enc}
+}
+
+// DecodeString 先按原格式解码, 失败时去掉填充后依次尝试补齐填充再解码
+func (enc *lenientEncoder) DecodeString(s string) ([]byte, error) {
+	b, err := enc.Encoder.DecodeString(s)
+	if err == nil {
+		return b, nil
+	}
+	t := strings.TrimRight(s, "=")
+	// base32的填充最多为6个字符
+	for pad := 0; pad <= 6; pad++ {
+		if b, e := enc.Encoder.DecodeString(t + strings.Repeat("=", pad)); e == nil {
+			return b, nil
+		}
+	}
+	return nil, err
+}
+
+// WithLenientDecoding 解码签名和body的hash值时同时接受有填充和没有填充的输入
+func WithLenientDecoding() Option {
+	return func(o *Options) {
+		o.LenientDecoding = true
+	}
+}
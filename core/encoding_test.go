@@ -0,0 +1,80 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoders(t *testing.T) {
+	b := []byte{0xfb, 0xff, 0x01, 0x02}
+	tests := []struct {
+		name string
+		enc  Encoder
+		want string
+	}{
+		{name: "Base64", enc: &Base64Encoder{}, want: "+/8BAg=="},
+		{name: "Base64URL", enc: &Base64URLEncoder{}, want: "-_8BAg"},
+		{name: "RawBase64", enc: &RawBase64Encoder{}, want: "+/8BAg"},
+		{name: "Base32", enc: &Base32Encoder{}, want: "7P7QCAQ="},
+		{name: "Hex", enc: &HexEncoder{}, want: "fbff0102"},
+		{name: "UpperHex", enc: &UpperHexEncoder{}, want: "FBFF0102"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.enc.EncodeToString(b)
+			assert.Equal(t, tt.want, s)
+			got, err := tt.enc.DecodeString(s)
+			assert.NoError(t, err)
+			assert.Equal(t, b, got)
+		})
+	}
+	got, err := (&UpperHexEncoder{}).DecodeString("fbff0102")
+	assert.NoError(t, err)
+	assert.Equal(t, b, got)
+}
+
+func TestLenient(t *testing.T) {
+	b := []byte{0xfb, 0xff, 0x01, 0x02}
+	tests := []struct {
+		name    string
+		enc     Encoder
+		s       string
+		wantErr bool
+	}{
+		{name: "Base64Padded", enc: &Base64Encoder{}, s: "+/8BAg=="},
+		{name: "Base64Unpadded", enc: &Base64Encoder{}, s: "+/8BAg"},
+		{name: "Base64URLPadded", enc: &Base64URLEncoder{}, s: "-_8BAg=="},
+		{name: "RawBase64Padded", enc: &RawBase64Encoder{}, s: "+/8BAg=="},
+		{name: "Base32Unpadded", enc: &Base32Encoder{}, s: "7P7QCAQ"},
+		{name: "HexUpper", enc: &HexEncoder{}, s: "FBFF0102"},
+		{name: "FailedInvalid", enc: &Base64Encoder{}, s: "+/8B!g==", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := Lenient(tt.enc)
+			assert.Equal(t, tt.enc.EncodeToString(b), enc.EncodeToString(b))
+			got, err := enc.DecodeString(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodeString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, b, got)
+			}
+		})
+	}
+	enc := Lenient(&Base64Encoder{})
+	assert.Equal(t, enc, Lenient(enc))
+}
+
+func TestWithLenientDecoding(t *testing.T) {
+	s := New(WithLenientDecoding())
+	// helloworld的sha256值, 没有填充
+	assert.NoError(t, s.ValidBody([]byte(`helloworld`), "k2oYXKqiZrucvpgengXLeM1zKwsygOuURBK7b4+PB68"))
+	assert.Error(t, New().ValidBody([]byte(`helloworld`), "k2oYXKqiZrucvpgengXLeM1zKwsygOuURBK7b4+PB68"))
+
+	a, err := New(WithLenientDecoding(), WithAllowedAlgorithms("sha256/base64url")).Negotiate("sha256/base64url")
+	assert.NoError(t, err)
+	assert.NoError(t, a.ValidBody([]byte(`helloworld`), "k2oYXKqiZrucvpgengXLeM1zKwsygOuURBK7b4-PB68="))
+}
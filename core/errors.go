@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// maxQuoteLen Quote保留的客户端输入的最大长度
+const maxQuoteLen = 64
+
+// Error 认证失败的错误, Error()返回可以发送给客户端的公开信息, 不包含客户端的输入;
+// Detail()返回用于日志的详细信息
+type Error struct {
+	public string
+	err    error
+}
+
+// Errorf 创建认证失败的错误, public为公开信息, format和args按fmt.Errorf格式化为详细信息,
+// 详细信息中的客户端输入应当使用Quote处理
+func Errorf(public, format string, args ...interface{}) error {
+	return &Error{public: public, err: fmt.Errorf(format, args...)}
+}
+
+// Error 返回公开信息
+func (e *Error) Error() string {
+	return e.public
+}
+
+// Detail 返回详细信息
+func (e *Error) Detail() string {
+	return e.err.Error()
+}
+
+// Unwrap 返回详细信息中使用%w包装的错误
+func (e *Error) Unwrap() error {
+	return errors.Unwrap(e.err)
+}
+
+// Detail 返回err的详细信息, err不是*Error时返回err.Error()
+func Detail(err error) string {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Detail()
+	}
+	return err.Error()
+}
+
+// Quote 截断并转义客户端的输入, 用于错误的详细信息
+func Quote(s string) string {
+	if len(s) > maxQuoteLen {
+		return strconv.Quote(s[:maxQuoteLen]) + "..."
+	}
+	return strconv.Quote(s)
+}
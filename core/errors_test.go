@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorf(t *testing.T) {
+	cause := errors.New("cause")
+	err := Errorf("public", "detail %s: %w", Quote("<input>"), cause)
+	assert.Equal(t, "public", err.Error())
+	assert.Equal(t, `detail "<input>": cause`, Detail(err))
+	assert.True(t, errors.Is(err, cause))
+	assert.Equal(t, "plain", Detail(errors.New("plain")))
+	assert.Equal(t, "", Detail(nil))
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{name: "Short", s: "abc", want: `"abc"`},
+		{name: "Escaped", s: "a\r\nb\x00", want: `"a\r\nb\x00"`},
+		{name: "Truncated", s: strings.Repeat("a", 100), want: strconv.Quote(strings.Repeat("a", maxQuoteLen)) + "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Quote(tt.s))
+		})
+	}
+}
+
+func TestAuth_ErrorsNotReflectInput(t *testing.T) {
+	s := New()
+	input := "<script>alert(1)</script>"
+	for _, err := range []error{
+		s.ParseTimestamp(input),
+		s.ValidSignature("sk", input, "a"),
+		s.ValidBody([]byte("body"), input),
+	} {
+		if assert.Error(t, err) {
+			assert.NotContains(t, err.Error(), input)
+			assert.Contains(t, Detail(err), strconv.Quote(input))
+		}
+	}
+	_, err := s.Negotiate(input)
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), input)
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// MinHashStrength 命名的hash算法默认要求的最小强度, 单位: 位, 即抗碰撞的安全强度
+const MinHashStrength = 112
+
+// hashEntry 注册的hash算法和强度
+type hashEntry struct {
+	h        HashFunc
+	strength int
+}
+
+func builtinHashes() map[string]hashEntry {
+	return map[string]hashEntry{
+		// 已经可以构造碰撞的算法, 只用于兼容旧的客户端
+		"md5":  {h: md5.New, strength: 18},
+		"sha1": {h: sha1.New, strength: 63},
+
+		"sha224":     {h: sha256.New224, strength: 112},
+		"sha256":     {h: sha256.New, strength: 128},
+		"sha384":     {h: sha512.New384, strength: 192},
+		"sha512":     {h: sha512.New, strength: 256},
+		"sha512-224": {h: sha512.New512_224, strength: 112},
+		"sha512-256": {h: sha512.New512_256, strength: 128},
+
+		"sha3-224": {h: sha3.New224, strength: 112},
+		"sha3-256": {h: sha3.New256, strength: 128},
+		"sha3-384": {h: sha3.New384, strength: 192},
+		"sha3-512": {h: sha3.New512, strength: 256},
+
+		"blake2b-256": {h: newBlake2b(blake2b.New256), strength: 128},
+		"blake2b-384": {h: newBlake2b(blake2b.New384), strength: 192},
+		"blake2b-512": {h: newBlake2b(blake2b.New512), strength: 256},
+	}
+}
+
+// newBlake2b 转换为不使用key的HashFunc
+func newBlake2b(f func(key []byte) (hash.Hash, error)) HashFunc {
+	return func() hash.Hash {
+		// key为空时不会返回错误
+		h, _ := f(nil)
+		return h
+	}
+}
+
+// RegisterHash 注册名称为name的hash算法, 已经存在时覆盖; 强度按输出长度的一半估计
+func RegisterHash(name string, h HashFunc) {
+	RegisterHashStrength(name, h, h().Size()*8/2)
+}
+
+// RegisterHashStrength 注册名称为name, 强度为strength位的hash算法, 已经存在时覆盖
+func RegisterHashStrength(name string, h HashFunc, strength int) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.hashes[normalizeName(name)] = hashEntry{h: h, strength: strength}
+}
+
+// LookupHash 查询名称为name的hash算法, 强度低于MinHashStrength时返回错误
+func LookupHash(name string) (HashFunc, error) {
+	e, err := lookupHash(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.strength < MinHashStrength {
+		return nil, fmt.Errorf("hash %q is too weak", name)
+	}
+	return e.h, nil
+}
+
+// LookupLegacyHash 查询名称为name的hash算法, 不检查强度, 只用于兼容旧的客户端
+func LookupLegacyHash(name string) (HashFunc, error) {
+	e, err := lookupHash(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.h, nil
+}
+
+func lookupHash(name string) (hashEntry, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	e, ok := registry.hashes[normalizeName(name)]
+	if !ok {
+		return hashEntry{}, fmt.Errorf("hash %q not found", name)
+	}
+	return e, nil
+}
+
+// HashStrength 返回名称为name的hash算法的强度
+func HashStrength(name string) (int, error) {
+	e, err := lookupHash(name)
+	if err != nil {
+		return 0, err
+	}
+	return e.strength, nil
+}
+
+// Hashes 返回已注册的hash算法名称
+func Hashes() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return sortedNames(registry.hashes)
+}
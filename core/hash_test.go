@@ -0,0 +1,61 @@
+package core
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupHash(t *testing.T) {
+	tests := []struct {
+		name       string
+		hash       string
+		size       int
+		wantErr    bool
+		wantLegacy bool
+	}{
+		{name: "SHA256", hash: "sha256", size: 32},
+		{name: "SHA512_256", hash: "SHA512-256", size: 32},
+		{name: "SHA3_512", hash: "sha3-512", size: 64},
+		{name: "BLAKE2b_256", hash: "blake2b-256", size: 32},
+		{name: "WeakSHA1", hash: "sha1", size: 20, wantErr: true, wantLegacy: true},
+		{name: "WeakMD5", hash: "md5", size: 16, wantErr: true, wantLegacy: true},
+		{name: "Unknown", hash: "md4", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := LookupHash(tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LookupHash() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.size, h().Size())
+				return
+			}
+			h, err = LookupLegacyHash(tt.hash)
+			if (err == nil) != tt.wantLegacy {
+				t.Errorf("LookupLegacyHash() error = %v, wantLegacy %v", err, tt.wantLegacy)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.size, h().Size())
+			}
+		})
+	}
+}
+
+func TestRegisterHashStrength(t *testing.T) {
+	RegisterHashStrength("test-weak", sha256.New, 64)
+	_, err := LookupHash("test-weak")
+	assert.Error(t, err)
+	n, err := HashStrength("test-weak")
+	assert.NoError(t, err)
+	assert.Equal(t, 64, n)
+	RegisterHash("test-strong", sha256.New)
+	n, _ = HashStrength("test-strong")
+	assert.Equal(t, 128, n)
+	_, err = HashStrength("md4")
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"crypto/hmac"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScopeDateFormat scope中日期的格式, 使用UTC时间
+const ScopeDateFormat = "20060102"
+
+// scopeTerminator 派生签名密钥的最后一步使用的常量
+const scopeTerminator = "aksk_request"
+
+// Scope 签名密钥的作用范围: 日期/区域/服务
+type Scope struct {
+	Date    string
+	Region  string
+	Service string
+}
+
+// NewScope 使用时间t的UTC日期创建作用范围
+func NewScope(t time.Time, region, service string) Scope {
+	return Scope{
+		Date:    t.UTC().Format(ScopeDateFormat),
+		Region:  region,
+		Service: service,
+	}
+}
+
+// ParseScope 解析格式为 日期/区域/服务 的字符串
+func ParseScope(s string) (Scope, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return Scope{}, errors.New("scope invalid")
+	}
+	sc := Scope{Date: parts[0], Region: parts[1], Service: parts[2]}
+	if _, err := time.Parse(ScopeDateFormat, sc.Date); err != nil {
+		return Scope{}, errors.New("scope date invalid")
+	}
+	if sc.Region == "" || sc.Service == "" {
+		return Scope{}, errors.New("scope invalid")
+	}
+	return sc, nil
+}
+
+// String 返回 日期/区域/服务 格式的字符串
+func (sc Scope) String() string {
+	return sc.Date + "/" + sc.Region + "/" + sc.Service
+}
+
+// WithScope 使用区域和服务限定签名密钥的作用范围.
+// 客户端使用私有密钥派生当天的签名密钥; 服务端只接受该区域和服务的签名密钥, 未限定范围的请求不受影响
+func WithScope(region, service string) Option {
+	return func(o *Options) {
+		o.Region = region
+		o.Service = service
+	}
+}
+
+// Scope 返回时间t对应的作用范围, 没有配置作用范围时返回false
+func (s *Auth) Scope(t time.Time) (Scope, bool) {
+	if s.region == "" || s.service == "" {
+		return Scope{}, false
+	}
+	return NewScope(t, s.region, s.service), true
+}
+
+// SigningKey 使用私有密钥派生作用范围内的签名密钥:
+// hmac(hmac(hmac(hmac(secret, date), region), service), "aksk_request")
+func (s *Auth) SigningKey(secret []byte, sc Scope) []byte {
+	key := secret
+	for _, v := range []string{sc.Date, sc.Region, sc.Service, scopeTerminator} {
+		h := hmac.New(s.h, key)
+		h.Write([]byte(v))
+		key = h.Sum(nil)
+	}
+	return key
+}
+
+// ValidScope 校验请求的作用范围: 日期必须和时间戳ts的UTC日期一致, 区域和服务必须与配置一致
+func (s *Auth) ValidScope(sc Scope, ts string) error {
+	if s.region == "" || s.service == "" {
+		return errors.New("scope is not accepted")
+	}
+	if sc.Region != s.region || sc.Service != s.service {
+		return Errorf("scope is not accepted", "scope %s not accepted", Quote(sc.String()))
+	}
+	n, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return Errorf("timestamp invalid", "timestamp %s invalid: %w", Quote(ts), err)
+	}
+	if date := time.Unix(n, 0).UTC().Format(ScopeDateFormat); date != sc.Date {
+		return Errorf("scope date invalid", "scope date %s not match timestamp %s", Quote(sc.Date), Quote(ts))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Scope
+		wantErr bool
+	}{
+		{name: "Ok", s: "20261018/cn-north/storage", want: Scope{Date: "20261018", Region: "cn-north", Service: "storage"}},
+		{name: "FailedParts", s: "20261018/cn-north", wantErr: true},
+		{name: "FailedDate", s: "2026-10-18/cn-north/storage", wantErr: true},
+		{name: "FailedEmptyService", s: "20261018/cn-north/", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScope(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseScope() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			if err == nil {
+				assert.Equal(t, tt.s, got.String())
+			}
+		})
+	}
+}
+
+func TestAuth_ValidScope(t *testing.T) {
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	s := New(WithScope("cn-north", "storage"))
+	tests := []struct {
+		name    string
+		s       *Auth
+		sc      Scope
+		ts      string
+		wantErr bool
+	}{
+		{name: "Ok", s: s, sc: NewScope(now, "cn-north", "storage"), ts: ts},
+		{name: "FailedNotConfigured", s: New(), sc: NewScope(now, "cn-north", "storage"), ts: ts, wantErr: true},
+		{name: "FailedRegion", s: s, sc: NewScope(now, "cn-south", "storage"), ts: ts, wantErr: true},
+		{name: "FailedService", s: s, sc: NewScope(now, "cn-north", "compute"), ts: ts, wantErr: true},
+		{name: "FailedDate", s: s, sc: NewScope(now.Add(-48*time.Hour), "cn-north", "storage"), ts: ts, wantErr: true},
+		{name: "FailedTimestamp", s: s, sc: NewScope(now, "cn-north", "storage"), ts: "150a0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.s.ValidScope(tt.sc, tt.ts); (err != nil) != tt.wantErr {
+				t.Errorf("Auth.ValidScope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuth_SigningKey(t *testing.T) {
+	s := New()
+	sc := Scope{Date: "20261018", Region: "cn-north", Service: "storage"}
+	key := s.SigningKey([]byte("456"), sc)
+	assert.Len(t, key, 32)
+	assert.Equal(t, key, s.SigningKey([]byte("456"), sc))
+	sc.Service = "compute"
+	assert.NotEqual(t, key, s.SigningKey([]byte("456"), sc))
+	_, ok := s.Scope(time.Now())
+	assert.False(t, ok)
+}
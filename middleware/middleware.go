@@ -2,7 +2,10 @@
 package middleware
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/qingtao/aksk/v2/core"
@@ -12,6 +15,16 @@ import (
 // ErrorHandler 错误处理函数, 接收错误处理后, 不再执行后续操作
 type ErrorHandler func(w http.ResponseWriter, err error)
 
+// ErrorLogger 记录认证失败的详细原因
+type ErrorLogger func(r *http.Request, err error)
+
+// ErrUnauthorized 开启UniformErrors时, 所有认证失败返回给客户端的错误
+var ErrUnauthorized = errors.New("unauthorized")
+
+func defaultErrorLogger(r *http.Request, err error) {
+	log.Printf("aksk: %s %s from %s: %s", r.Method, core.Quote(r.URL.Path), r.RemoteAddr, core.Detail(err))
+}
+
 func defaultErrorHandler(w http.ResponseWriter, err error) {
 	if err == nil {
 		return
@@ -22,8 +35,12 @@ func defaultErrorHandler(w http.ResponseWriter, err error) {
 
 // Middleware 中间件
 type Middleware struct {
-	Validator    request.Validator
-	errorHandler ErrorHandler
+	Validator     request.Validator
+	Signer        request.ResponseSigner
+	errorHandler  ErrorHandler
+	errorLogger   ErrorLogger
+	uniformErrors bool
+	decompress    bool
 }
 
 // Config 配置
@@ -32,6 +49,36 @@ type Config struct {
 	KeyGetter    core.KeyGetter
 	SkipBody     bool
 	ErrorHandler ErrorHandler
+	// 对响应签名, 签名绑定请求的签名, 客户端可以校验响应是否被篡改
+	SignResponse bool
+	// 签名头部的名称, 为空的字段使用默认的名称
+	HeaderNames request.HeaderNames
+	// 所有的认证失败都以ErrUnauthorized传给ErrorHandler, 客户端无法区分失败的原因
+	UniformErrors bool
+	// 记录认证失败的详细原因, UniformErrors为true并且ErrorLogger为nil时使用标准库log输出
+	ErrorLogger ErrorLogger
+	// 签名不一致时在响应中返回服务端的待签名字符串和body的hash值, 不受UniformErrors影响, 只用于调试
+	Debug bool
+	// 只对这些访问密钥开启调试
+	DebugAccessKeys []string
+	// 拒绝旧的签名方案的请求, 只接受request.Version2及以后的版本
+	RejectLegacy bool
+	// 接受的最低版本, 例如: request.Version3
+	MinVersion request.Version
+	// 按照请求方法和路由返回body策略, 例如: request.BodyPolicyRules(...)
+	BodyPolicy request.BodyPolicyFunc
+	// 返回是否接受声明request.UnsignedPayload的请求, 例如: request.MatchRoutes(...)
+	AllowUnsignedPayload func(r *http.Request) bool
+	// 校验签名后按照Content-Encoding解码body, 处理函数读取解码后的body
+	Decompress bool
+	// 受信任的代理的网段(CIDR)或者IP, 参考request.ValidatorConfig.TrustedProxies
+	TrustedProxies []string
+	// 受信任的代理负责设置的头部, 参考request.ValidatorConfig.ForwardedHeaders
+	ForwardedHeaders []string
+	// 规范化multipart/form-data的body时在内存中保存的最大长度, 为0时使用request.MaxBodyMemory
+	MaxBodyMemory int64
+	// 规范化multipart/form-data的body时保存的最大长度, 为0时使用request.MaxSpoolSize
+	MaxSpoolSize int64
 }
 
 // New 新建一个中间件
@@ -39,32 +86,121 @@ func New(cfg Config, opts ...core.Option) *Middleware {
 	if cfg.KeyGetter == nil {
 		panic("Config.Key is nil")
 	}
-	validator, err := request.NewValidatorFunc(cfg.KeyGetter, cfg.SkipBody, opts...)
+	validator, err := request.NewValidator(request.ValidatorConfig{
+		KeyGetter:            cfg.KeyGetter,
+		SkipBody:             cfg.SkipBody,
+		HeaderNames:          cfg.HeaderNames,
+		Debug:                cfg.Debug,
+		DebugAccessKeys:      cfg.DebugAccessKeys,
+		RejectLegacy:         cfg.RejectLegacy,
+		MinVersion:           cfg.MinVersion,
+		BodyPolicy:           cfg.BodyPolicy,
+		AllowUnsignedPayload: cfg.AllowUnsignedPayload,
+		TrustedProxies:       cfg.TrustedProxies,
+		ForwardedHeaders:     cfg.ForwardedHeaders,
+		MaxBodyMemory:        cfg.MaxBodyMemory,
+		MaxSpoolSize:         cfg.MaxSpoolSize,
+	}, opts...)
 	if err != nil {
 		panic(err)
 	}
 	middleware := &Middleware{
-		Validator:    validator,
-		errorHandler: cfg.ErrorHandler,
+		Validator:     validator,
+		errorHandler:  cfg.ErrorHandler,
+		errorLogger:   cfg.ErrorLogger,
+		uniformErrors: cfg.UniformErrors,
+		decompress:    cfg.Decompress,
 	}
 	if middleware.errorHandler == nil {
 		middleware.errorHandler = defaultErrorHandler
 	}
+	if middleware.uniformErrors && middleware.errorLogger == nil {
+		middleware.errorLogger = defaultErrorLogger
+	}
+	if cfg.SignResponse {
+		signer, err := request.NewResponseSigner(cfg.KeyGetter, cfg.HeaderNames, opts...)
+		if err != nil {
+			panic(err)
+		}
+		middleware.Signer = signer
+	}
 	return middleware
 }
 
 // Handle 验证请求, 成功后调用handler.ServeHTTP(w,r)
 func (m *Middleware) Handle(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 校验失败的请求无法确定签名密钥, 错误响应不签名, 客户端得到request.UnsignedResponseError
 		if err := m.Validator.Validate(r); err != nil {
+			m.fail(w, r, err)
+			return
+		}
+		// 校验通过之后的错误响应和处理函数的响应一样签名
+		serve := func(w http.ResponseWriter) {
+			if m.decompress {
+				if err := request.DecompressBody(r); err != nil {
+					m.fail(w, r, err)
+					return
+				}
+			}
+			handler.ServeHTTP(w, r)
+		}
+		if m.Signer == nil {
+			serve(w)
+			return
+		}
+		rw := &responseWriter{ResponseWriter: w}
+		serve(rw)
+		if err := m.Signer.SignResponse(r, rw.statusCode(), w.Header(), rw.buf.Bytes()); err != nil {
 			m.errorHandler(w, err)
 			return
 		}
-		handler.ServeHTTP(w, r)
+		w.WriteHeader(rw.statusCode())
+		w.Write(rw.buf.Bytes())
 	})
 }
 
+// fail 记录认证失败的原因并调用错误处理函数
+func (m *Middleware) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if m.errorLogger != nil {
+		m.errorLogger(r, err)
+	}
+	// 开启调试时保留服务端的待签名字符串
+	var mismatch *request.SignatureMismatchError
+	if m.uniformErrors && !errors.As(err, &mismatch) {
+		err = ErrUnauthorized
+	}
+	m.errorHandler(w, err)
+}
+
 // HandleFunc 验证请求, 成功后调用handler(w,r)
 func (m *Middleware) HandleFunc(handler http.HandlerFunc) http.Handler {
 	return m.Handle(http.Handler(handler))
 }
+
+// responseWriter 缓存响应的状态码和body, 用于计算响应的签名
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+// WriteHeader 记录状态码, 只有第一次调用有效
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write 写入缓存
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.buf.Write(b)
+}
+
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
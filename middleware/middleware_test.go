@@ -2,11 +2,14 @@ package middleware
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/qingtao/aksk/v2/request"
@@ -163,3 +166,255 @@ func Test_defaultErrorHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestMiddlewareSignResponse(t *testing.T) {
+	m := New(Config{KeyGetter: getSecretKey, SignResponse: true})
+	server := httptest.NewServer(m.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "helloworld!")
+	}))
+	defer server.Close()
+
+	modifier, _ := request.NewModifierFunc("123", "456", false)
+	verifier, _ := request.NewResponseVerifierFunc("456")
+	client := &http.Client{
+		Transport: &request.Transport{Modifier: modifier, ResponseVerifier: verifier},
+	}
+	resp, err := client.Post(server.URL, "text/plain", bytes.NewReader([]byte(`helloworld`)))
+	if err != nil {
+		t.Fatalf("client.Post error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expect StatusCode %v, but got %v", http.StatusCreated, resp.StatusCode)
+	}
+}
+
+func TestMiddlewareSignResponseWithoutBody(t *testing.T) {
+	m := New(Config{KeyGetter: getSecretKey, SignResponse: true})
+	server := httptest.NewServer(m.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		fmt.Fprint(w, "<html>helloworld!</html>")
+	}))
+	defer server.Close()
+	modifier, _ := request.NewModifierFunc("123", "456", false)
+	verifier, _ := request.NewResponseVerifierFunc("456")
+	client := &http.Client{Transport: &request.Transport{Modifier: modifier, ResponseVerifier: verifier}}
+	// net/http不发送HEAD请求和204响应的body
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{method: http.MethodGet, path: "/", want: http.StatusOK},
+		{method: http.MethodHead, path: "/", want: http.StatusOK},
+		{method: http.MethodGet, path: "/empty", want: http.StatusNoContent},
+		{method: http.MethodHead, path: "/empty", want: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequestWithContext(context.TODO(), tt.method, server.URL+tt.path, nil)
+		resp, err := client.Do(r)
+		if err != nil {
+			t.Errorf("%s %s error = %v", tt.method, tt.path, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s expect StatusCode %v, but got %v", tt.method, tt.path, tt.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestMiddlewareSignErrorResponse(t *testing.T) {
+	m := New(Config{KeyGetter: getSecretKey, SignResponse: true, Decompress: true})
+	server := httptest.NewServer(m.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "helloworld!")
+	}))
+	defer server.Close()
+	verifier, _ := request.NewResponseVerifierFunc("456")
+	newClient := func(sk string) *http.Client {
+		modifier, _ := request.NewModifierFunc("123", sk, false)
+		return &http.Client{Transport: &request.Transport{Modifier: modifier, ResponseVerifier: verifier}}
+	}
+	// 校验失败的错误响应不签名, 客户端可以得到状态码
+	_, err := newClient("abc").Post(server.URL, "text/plain", strings.NewReader("helloworld"))
+	var unsigned *request.UnsignedResponseError
+	if !errors.As(err, &unsigned) || unsigned.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expect UnsignedResponseError %v, but got %v", http.StatusUnauthorized, err)
+	}
+	// 校验通过之后的错误响应签名
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, server.URL, strings.NewReader("helloworld"))
+	r.Header.Set("Content-Encoding", "gzip")
+	resp, err := newClient("456").Do(r)
+	if err != nil {
+		t.Fatalf("client.Do error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expect StatusCode %v, but got %v", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestMiddlewareHeaderNames(t *testing.T) {
+	names := request.NewHeaderNames("x-acme-")
+	m := New(Config{KeyGetter: getSecretKey, HeaderNames: names})
+	handler := m.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "helloworld!")
+	})
+	modifier, _ := request.NewModifier(request.ModifierConfig{AccessKey: "123", SecretKey: "456", HeaderNames: names})
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`helloworld`)))
+	modifier(r)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expect StatusCode %v, but got %v", http.StatusOK, w.Code)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, goodTestRequest("http://example.com/"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expect StatusCode %v, but got %v", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestMiddlewareUniformErrors(t *testing.T) {
+	var logged []error
+	m := New(Config{
+		KeyGetter:     getSecretKey,
+		UniformErrors: true,
+		ErrorLogger: func(r *http.Request, err error) {
+			logged = append(logged, err)
+		},
+	})
+	handler := m.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "helloworld!")
+	})
+	badTimestamp := goodTestRequest("http://example.com/")
+	badTimestamp.Header.Set(request.HeaderTimestamp, "1570000000")
+	unknownKey := goodTestRequest("http://example.com/")
+	unknownKey.Header.Set(request.HeaderAccessKey, "wantEmpty")
+	var bodies []string
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/", nil),
+		badTimestamp,
+		unknownKey,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expect StatusCode %v, but got %v", http.StatusUnauthorized, w.Code)
+		}
+		bodies = append(bodies, w.Body.String())
+	}
+	for _, body := range bodies {
+		if body != ErrUnauthorized.Error() {
+			t.Errorf("expect body %q, but got %q", ErrUnauthorized.Error(), body)
+		}
+	}
+	if len(logged) != 3 || errors.Is(logged[0], ErrUnauthorized) {
+		t.Errorf("expect detailed errors logged, but got %v", logged)
+	}
+}
+
+func TestMiddlewareErrorsNotReflectInput(t *testing.T) {
+	m := New(Config{
+		KeyGetter:   getSecretKey,
+		ErrorLogger: func(r *http.Request, err error) {},
+	})
+	handler := m.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "helloworld!")
+	})
+	input := "<script>alert(1)</script>"
+	badTimestamp := goodTestRequest("http://example.com/")
+	badTimestamp.Header.Set(request.HeaderTimestamp, input)
+	badSignature := goodTestRequest("http://example.com/")
+	badSignature.Header.Set(request.HeaderSignature, input)
+	badAlgorithm := goodTestRequest("http://example.com/")
+	badAlgorithm.Header.Set(request.HeaderAlgorithm, input)
+	for _, r := range []*http.Request{badTimestamp, badSignature, badAlgorithm} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expect StatusCode %v, but got %v", http.StatusUnauthorized, w.Code)
+		}
+		if strings.Contains(w.Body.String(), input) {
+			t.Errorf("expect body without input, but got %q", w.Body.String())
+		}
+	}
+}
+
+func TestMiddlewareDebug(t *testing.T) {
+	m := New(Config{
+		KeyGetter: func(ak string) (string, error) {
+			return "789", nil
+		},
+		UniformErrors:   true,
+		ErrorLogger:     func(r *http.Request, err error) {},
+		DebugAccessKeys: []string{"123"},
+	})
+	handler := m.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "helloworld!")
+	})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, goodTestRequest("http://example.com/"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expect StatusCode %v, but got %v", http.StatusUnauthorized, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "canonical string") {
+		t.Errorf("expect canonical string in body, but got %q", w.Body.String())
+	}
+}
+
+func TestMiddlewareStreamingPayload(t *testing.T) {
+	m := New(Config{KeyGetter: getSecretKey})
+	srv := httptest.NewServer(m.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+	modifier, _ := request.NewModifier(request.ModifierConfig{AccessKey: "123", SecretKey: "456", Version: request.Version2, ChunkSize: 4})
+	body := strings.Repeat("helloworld", 10)
+	r, _ := http.NewRequestWithContext(request.WithStreamingPayload(context.TODO()), http.MethodPut, srv.URL, strings.NewReader(body))
+	if err := modifier(r); err != nil {
+		t.Fatalf("ModifyRequest() error = %v", err)
+	}
+	resp, err := srv.Client().Do(r)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(b) != body {
+		t.Errorf("expect %v %q, but got %v %q", http.StatusOK, body, resp.StatusCode, b)
+	}
+}
+
+func TestMiddlewareDecompress(t *testing.T) {
+	m := New(Config{KeyGetter: getSecretKey, Decompress: true})
+	handler := m.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write(b)
+	})
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("helloworld"))
+	zw.Close()
+	modifier, _ := request.NewModifier(request.ModifierConfig{AccessKey: "123", SecretKey: "456", Version: request.Version2, BodyHashMode: request.BodyHashDecoded})
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, "http://example.com/", &buf)
+	r.Header.Set("Content-Encoding", "gzip")
+	modifier(r)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "helloworld" {
+		t.Errorf("expect %v %q, but got %v %q", http.StatusOK, "helloworld", w.Code, w.Body.String())
+	}
+}
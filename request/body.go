@@ -0,0 +1,185 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+// UnsignedPayload 不对body签名时x-auth-body-hash的值, 参与签名; 服务端必须明确允许
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// contextKey 修改请求时使用的context的键, 所有的键在这里声明
+type contextKey int
+
+const (
+	unsignedPayloadKey contextKey = iota
+	streamingPayloadKey
+	trailerPayloadKey
+	bodyHashKey
+)
+
+// WithUnsignedPayload 返回的context用于创建请求时, 修改请求不计算body的hash值, 而是声明UnsignedPayload,
+// 适用于无法读入内存的上传
+func WithUnsignedPayload(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unsignedPayloadKey, true)
+}
+
+// isUnsignedPayload 请求是否声明不对body签名
+func isUnsignedPayload(ctx context.Context) bool {
+	v, _ := ctx.Value(unsignedPayloadKey).(bool)
+	return v
+}
+
+// WithBodyHash 返回的context用于创建请求时, 修改请求直接使用预先计算的body的hash值sum签名, 不读取body,
+// 适用于已知hash值的文件, 例如对象存储中的文件. sum是未编码的摘要, 必须使用签名的算法对原始的body计算,
+// 不解码也不规范化; 服务端仍然读取并校验body
+func WithBodyHash(ctx context.Context, sum []byte) context.Context {
+	return context.WithValue(ctx, bodyHashKey, sum)
+}
+
+// precomputedBodyHash 返回请求预先计算的body的hash值
+func precomputedBodyHash(ctx context.Context) ([]byte, bool) {
+	sum, ok := ctx.Value(bodyHashKey).([]byte)
+	return sum, ok
+}
+
+// Route 按照请求方法和路径前缀匹配请求
+type Route struct {
+	// 请求方法, 为空时匹配所有的方法
+	Method string
+	// 路径前缀, 为空时匹配所有的路径; /upload/和/upload等价
+	PathPrefix string
+}
+
+// Match 请求是否匹配: 路径前缀只在路径分段的边界匹配, 例如/upload匹配/upload和/upload/a, 不匹配/uploads;
+// 没有规范化的路径(包含.., .或者//)不匹配任何路由
+func (r Route) Match(req *http.Request) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	if req.URL == nil || !CanonicalPath(req.URL.Path) {
+		return false
+	}
+	prefix := strings.TrimRight(r.PathPrefix, "/")
+	return prefix == "" || req.URL.Path == prefix || strings.HasPrefix(req.URL.Path, prefix+"/")
+}
+
+// CanonicalPath 路径是否已经规范化, 允许末尾的斜杠, 空路径等同于/; 没有规范化的路径可能匹配一个路由之后由上游规范化到其他的路径
+func CanonicalPath(p string) bool {
+	if p == "" {
+		return true
+	}
+	if !strings.HasPrefix(p, "/") {
+		return false
+	}
+	cleaned := path.Clean(p)
+	return p == cleaned || p == cleaned+"/"
+}
+
+// MatchRoutes 返回请求是否匹配任意一个路由的函数
+func MatchRoutes(routes ...Route) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		for _, r := range routes {
+			if r.Match(req) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// BodyPolicy 请求body的策略
+type BodyPolicy int
+
+const (
+	// BodyOptional body可以为空, 默认的策略
+	BodyOptional BodyPolicy = iota
+	// BodyRequired body不能为空
+	BodyRequired
+	// BodyForbidden body必须为空
+	BodyForbidden
+)
+
+// BodyPolicyFunc 返回请求使用的body策略
+type BodyPolicyFunc func(req *http.Request) BodyPolicy
+
+// errBodyRequired BodyRequired的请求没有body
+var errBodyRequired = errors.New("body is required")
+
+// checkUnreadBody 校验不预先读取的body(分块签名, trailer, UnsignedPayload)的策略: BodyForbidden拒绝所有的请求,
+// BodyRequired拒绝明确没有body的请求, 长度未知的body由requireBody在读取时检查
+func checkUnreadBody(req *http.Request, policy BodyPolicy) error {
+	switch {
+	case policy == BodyForbidden:
+		return errors.New("body is forbidden")
+	case policy == BodyRequired && (req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0):
+		return errBodyRequired
+	}
+	return nil
+}
+
+// requireBody 策略为BodyRequired时包装req.Body, 读取结束时body仍然为空返回错误
+func requireBody(req *http.Request, policy BodyPolicy) {
+	if policy == BodyRequired {
+		req.Body = &requiredBody{ReadCloser: req.Body}
+	}
+}
+
+// requiredBody 读取结束时没有读到任何字节返回errBodyRequired
+type requiredBody struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read 实现io.Reader
+func (b *requiredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF && b.n == 0 {
+		return n, errBodyRequired
+	}
+	return n, err
+}
+
+// BodyPolicyRule 按照请求方法和路径前缀匹配的body策略
+type BodyPolicyRule struct {
+	// 请求方法, 为空时匹配所有的方法
+	Method string
+	// 路径前缀, 为空时匹配所有的路径
+	PathPrefix string
+	Policy     BodyPolicy
+}
+
+// BodyPolicyRules 按顺序使用第一个匹配的规则, 都不匹配时返回BodyOptional
+func BodyPolicyRules(rules ...BodyPolicyRule) BodyPolicyFunc {
+	return func(req *http.Request) BodyPolicy {
+		for _, rule := range rules {
+			if (Route{Method: rule.Method, PathPrefix: rule.PathPrefix}).Match(req) {
+				return rule.Policy
+			}
+		}
+		return BodyOptional
+	}
+}
+
+// checkBody 按照策略校验body和body的hash值:
+// body非空时hash值不能为空; body为空时hash值可以为空, 非空时必须是空body的hash值
+func checkBody(a *core.Auth, b []byte, bodyHash string, policy BodyPolicy) error {
+	switch {
+	case len(b) == 0 && policy == BodyRequired:
+		return errors.New("body is required")
+	case len(b) > 0 && policy == BodyForbidden:
+		return errors.New("body is forbidden")
+	case len(b) > 0 && bodyHash == "":
+		return errors.New("the mac of body is empty")
+	case len(b) == 0 && bodyHash == "":
+		return nil
+	}
+	return a.ValidBodyHash(b, bodyHash)
+}
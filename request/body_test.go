@@ -0,0 +1,245 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyPolicyRules(t *testing.T) {
+	policy := BodyPolicyRules(
+		BodyPolicyRule{Method: http.MethodGet, Policy: BodyForbidden},
+		BodyPolicyRule{Method: http.MethodPost, PathPrefix: "/upload", Policy: BodyRequired},
+	)
+	tests := []struct {
+		method string
+		path   string
+		want   BodyPolicy
+	}{
+		{method: http.MethodGet, path: "/upload", want: BodyForbidden},
+		{method: http.MethodPost, path: "/upload/file", want: BodyRequired},
+		{method: http.MethodPost, path: "/api", want: BodyOptional},
+		{method: http.MethodPut, path: "/upload", want: BodyOptional},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		assert.Equal(t, tt.want, policy(r), tt.method+" "+tt.path)
+	}
+}
+
+func TestValidatorBodyPolicy(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	// newRequest 对body签名, 然后把请求的body替换为sent
+	newRequest := func(signed io.Reader, sent io.Reader) *http.Request {
+		modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version2})
+		r, _ := http.NewRequestWithContext(context.TODO(), "POST", "http://example.com/", signed)
+		modifier(r)
+		r.Body = nil
+		if sent != nil {
+			r.Body = ioutil.NopCloser(sent)
+		}
+		return r
+	}
+	tests := []struct {
+		name    string
+		policy  BodyPolicy
+		signed  io.Reader
+		sent    io.Reader
+		wantErr bool
+	}{
+		{name: "OkBody", signed: strings.NewReader("helloworld"), sent: strings.NewReader("helloworld")},
+		{name: "OkNoBody"},
+		{name: "OkEmptyBody", signed: http.NoBody, sent: http.NoBody},
+		{name: "OkRequired", policy: BodyRequired, signed: strings.NewReader("helloworld"), sent: strings.NewReader("helloworld")},
+		{name: "OkForbidden", policy: BodyForbidden},
+		{name: "FailedHashWithoutBody", signed: strings.NewReader("helloworld"), wantErr: true},
+		{name: "FailedHashWithEmptyBody", signed: strings.NewReader("helloworld"), sent: http.NoBody, wantErr: true},
+		{name: "FailedBodyWithoutHash", sent: strings.NewReader("helloworld"), wantErr: true},
+		{name: "FailedRequired", policy: BodyRequired, wantErr: true},
+		{name: "FailedRequiredEmpty", policy: BodyRequired, signed: http.NoBody, sent: http.NoBody, wantErr: true},
+		{name: "FailedForbidden", policy: BodyForbidden, signed: strings.NewReader("helloworld"), sent: strings.NewReader("helloworld"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := tt.policy
+			validator, _ := NewValidator(ValidatorConfig{
+				KeyGetter:  getKey,
+				BodyPolicy: func(*http.Request) BodyPolicy { return policy },
+			})
+			if err := validator(newRequest(tt.signed, tt.sent)); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatchRoutes(t *testing.T) {
+	match := MatchRoutes(Route{Method: http.MethodPut, PathPrefix: "/upload"}, Route{PathPrefix: "/stream"})
+	assert.True(t, match(httptest.NewRequest(http.MethodPut, "/upload/file", nil)))
+	assert.True(t, match(httptest.NewRequest(http.MethodGet, "/stream", nil)))
+	assert.False(t, match(httptest.NewRequest(http.MethodPost, "/upload/file", nil)))
+	assert.False(t, MatchRoutes()(httptest.NewRequest(http.MethodGet, "/", nil)))
+	// 路径前缀只在分段的边界匹配, 没有规范化的路径不匹配
+	assert.True(t, match(httptest.NewRequest(http.MethodPut, "/upload", nil)))
+	assert.False(t, match(httptest.NewRequest(http.MethodPut, "/uploads-admin", nil)))
+	assert.False(t, match(httptest.NewRequest(http.MethodPut, "/upload/../admin", nil)))
+	assert.False(t, match(httptest.NewRequest(http.MethodPut, "/upload//file", nil)))
+	assert.True(t, MatchRoutes(Route{PathPrefix: "/upload/"})(httptest.NewRequest(http.MethodPut, "/upload", nil)))
+	assert.True(t, MatchRoutes(Route{})(httptest.NewRequest(http.MethodPut, "/any/", nil)))
+}
+
+func TestUnsignedPayload(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version2})
+	newRequest := func(path string, unsigned bool) *http.Request {
+		ctx := context.TODO()
+		if unsigned {
+			ctx = WithUnsignedPayload(ctx)
+		}
+		r, _ := http.NewRequestWithContext(ctx, http.MethodPut, "http://example.com"+path, strings.NewReader("helloworld"))
+		modifier(r)
+		return r
+	}
+	validator, _ := NewValidator(ValidatorConfig{
+		KeyGetter:            getKey,
+		AllowUnsignedPayload: MatchRoutes(Route{Method: http.MethodPut, PathPrefix: "/upload"}),
+	})
+	tests := []struct {
+		name    string
+		r       func() *http.Request
+		wantErr bool
+	}{
+		{name: "OkUnsigned", r: func() *http.Request { return newRequest("/upload", true) }},
+		{name: "OkSigned", r: func() *http.Request { return newRequest("/upload", false) }},
+		{
+			name: "OkUnsignedBodyChanged",
+			r: func() *http.Request {
+				r := newRequest("/upload", true)
+				r.Body = ioutil.NopCloser(strings.NewReader("changed"))
+				return r
+			},
+		},
+		{name: "FailedRouteNotAllowed", r: func() *http.Request { return newRequest("/api", true) }, wantErr: true},
+		{name: "FailedRouteBoundary", r: func() *http.Request { return newRequest("/uploads-admin", true) }, wantErr: true},
+		{name: "FailedDotSegments", r: func() *http.Request { return newRequest("/upload/../admin", true) }, wantErr: true},
+		{name: "FailedDotSegmentsSigned", r: func() *http.Request { return newRequest("/upload/../admin", false) }, wantErr: true},
+		{
+			name: "FailedMarkerAdded",
+			r: func() *http.Request {
+				r := newRequest("/upload", false)
+				r.Header.Set(HeaderBodyHash, UnsignedPayload)
+				return r
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validator(tt.r()); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	r := newRequest("/upload", true)
+	assert.Equal(t, UnsignedPayload, r.Header.Get(HeaderBodyHash))
+	validator, _ = NewValidator(ValidatorConfig{KeyGetter: getKey})
+	assert.Error(t, validator(r))
+	// 接受UnsignedPayload的路由仍然适用BodyForbidden
+	validator, _ = NewValidator(ValidatorConfig{
+		KeyGetter:            getKey,
+		BodyPolicy:           func(*http.Request) BodyPolicy { return BodyForbidden },
+		AllowUnsignedPayload: func(*http.Request) bool { return true },
+	})
+	assert.EqualError(t, validator(newRequest("/upload", true)), "body is forbidden")
+}
+
+// unreadable 读取时返回错误的body, 用于确认修改请求不读取body
+type unreadable struct{}
+
+func (unreadable) Read(p []byte) (int, error) { return 0, errors.New("body read") }
+
+func TestWithBodyHash(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	validator, _ := NewValidator(ValidatorConfig{KeyGetter: getKey})
+	sum := core.New().Sum([]byte("helloworld"))
+	newRequest := func(version Version, sum []byte, body string) (*http.Request, error) {
+		modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: version})
+		r, _ := http.NewRequestWithContext(WithBodyHash(context.TODO(), sum), http.MethodPut, httptest.DefaultRemoteAddr, unreadable{})
+		if err := modifier(r); err != nil {
+			return nil, err
+		}
+		r.Body = ioutil.NopCloser(strings.NewReader(body))
+		return r, nil
+	}
+	tests := []struct {
+		name       string
+		version    Version
+		sum        []byte
+		body       string
+		wantErr    bool
+		wantModErr bool
+	}{
+		{name: "Ok", version: Version2, sum: sum, body: "helloworld"},
+		{name: "FailedBodyChanged", version: Version2, sum: sum, body: "changed", wantErr: true},
+		{name: "FailedLegacy", version: VersionLegacy, sum: sum, wantModErr: true},
+		{name: "FailedSizeMismatch", version: Version2, sum: sum[:8], wantModErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRequest(tt.version, tt.sum, tt.body)
+			if (err != nil) != tt.wantModErr {
+				t.Errorf("ModifyRequest() error = %v, wantErr %v", err, tt.wantModErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if err := validator(r); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatorBodyRequiredUnread(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version3})
+	validator, _ := NewValidator(ValidatorConfig{
+		KeyGetter:            getKey,
+		BodyPolicy:           func(*http.Request) BodyPolicy { return BodyRequired },
+		AllowUnsignedPayload: func(*http.Request) bool { return true },
+	})
+	modes := map[string]func(context.Context) context.Context{
+		"Streaming": WithStreamingPayload,
+		"Trailer":   WithTrailerPayload,
+		"Unsigned":  WithUnsignedPayload,
+	}
+	for name, with := range modes {
+		for _, body := range []string{"helloworld", ""} {
+			t.Run(name+"/"+body, func(t *testing.T) {
+				// 不预先读取的body: 没有body时校验或者读取body返回错误
+				r, _ := http.NewRequestWithContext(with(context.TODO()), http.MethodPut, "http://example.com/upload", strings.NewReader(body))
+				if err := modifier(r); err != nil {
+					t.Fatalf("ModifyRequest() error = %v", err)
+				}
+				err := validator(r)
+				if err == nil {
+					_, err = ioutil.ReadAll(r.Body)
+				}
+				if body == "" {
+					assert.EqualError(t, err, "body is required")
+					return
+				}
+				assert.NoError(t, err)
+			})
+		}
+	}
+}
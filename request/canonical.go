@@ -0,0 +1,78 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+// HeaderCanonicalization 计算hash值之前对body使用的规范化方法, 参与签名; 为空时不规范化
+const HeaderCanonicalization = `x-auth-canonicalization`
+
+// Canonicalization body的规范化方法
+type Canonicalization string
+
+const (
+	// CanonicalizationNone 不规范化, 默认的方法
+	CanonicalizationNone Canonicalization = ""
+	// CanonicalizationJCS 按照RFC 8785规范化application/json的body, 代理重新排列键或者修改空白时签名仍然有效
+	CanonicalizationJCS Canonicalization = "jcs"
+	// CanonicalizationForm 规范化application/x-www-form-urlencoded和multipart/form-data的body,
+	// 不受字段的顺序, 转义和multipart的boundary的影响
+	CanonicalizationForm Canonicalization = "form"
+)
+
+// valid 是否支持的方法
+func (c Canonicalization) valid() bool {
+	return c == CanonicalizationNone || c == CanonicalizationJCS || c == CanonicalizationForm
+}
+
+// applicable 规范化方法是否适用于请求的Content-Type
+func (c Canonicalization) applicable(header http.Header) bool {
+	switch c {
+	case CanonicalizationJCS:
+		return isJSON(header.Get("Content-Type"))
+	case CanonicalizationForm:
+		contentType := header.Get("Content-Type")
+		return isForm(contentType) || multipartBoundary(contentType) != ""
+	}
+	return true
+}
+
+// canonicalize 规范化body, body为空时不处理
+func (c Canonicalization) canonicalize(a *core.Auth, b []byte, header http.Header) ([]byte, error) {
+	if c == CanonicalizationNone || len(b) == 0 {
+		return b, nil
+	}
+	if !c.applicable(header) {
+		return nil, errors.New("canonicalization not applicable to content type")
+	}
+	if c == CanonicalizationJCS {
+		return CanonicalJSON(b)
+	}
+	if boundary := multipartBoundary(header.Get("Content-Type")); boundary != "" {
+		return canonicalMultipart(a, bytes.NewReader(b), boundary)
+	}
+	return canonicalForm(b)
+}
+
+// checkCanonicalization 校验请求的规范化方法
+func checkCanonicalization(c Canonicalization) error {
+	if !c.valid() {
+		return core.Errorf("canonicalization not supported", "canonicalization %s not supported", core.Quote(string(c)))
+	}
+	return nil
+}
+
+// isJSON Content-Type是否为application/json或者+json后缀的类型
+func isJSON(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return t == "application/json" || strings.HasSuffix(t, "+json")
+}
@@ -0,0 +1,73 @@
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalization(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	validator, _ := NewValidator(ValidatorConfig{KeyGetter: getKey})
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version2, Canonicalization: CanonicalizationJCS})
+	newRequest := func(contentType, body string) *http.Request {
+		r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, httptest.DefaultRemoteAddr, strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		if err := modifier(r); err != nil {
+			t.Fatalf("ModifyRequest() error = %v", err)
+		}
+		return r
+	}
+	// reserialize 模拟代理重新序列化JSON
+	reserialize := func(r *http.Request, body string) *http.Request {
+		r.Body = ioutil.NopCloser(strings.NewReader(body))
+		return r
+	}
+	tests := []struct {
+		name    string
+		r       *http.Request
+		wantErr bool
+	}{
+		{name: "OkJSON", r: newRequest("application/json", `{"b":1,"a":[1,2]}`)},
+		{name: "OkReserialized", r: reserialize(newRequest("application/json; charset=utf-8", `{"b":1,"a":[1,2]}`), "{\n  \"a\": [1, 2.0],\n  \"b\": 1\n}")},
+		{name: "OkSuffixJSON", r: reserialize(newRequest("application/problem+json", `{"b":1,"a":2}`), `{"a":2,"b":1}`)},
+		{name: "OkNotJSON", r: newRequest("text/plain", `{"b":1,"a":2}`)},
+		{name: "FailedValueChanged", r: reserialize(newRequest("application/json", `{"b":1,"a":2}`), `{"a":2,"b":2}`), wantErr: true},
+		{name: "FailedNotJSONReserialized", r: reserialize(newRequest("text/plain", `{"b":1,"a":2}`), `{"a":2,"b":1}`), wantErr: true},
+		{
+			name: "FailedContentTypeChanged",
+			r: func() *http.Request {
+				r := newRequest("application/json", `{"b":1,"a":2}`)
+				r.Header.Set("Content-Type", "text/plain")
+				return r
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "FailedUnknown",
+			r: func() *http.Request {
+				r := newRequest("application/json", `{"b":1,"a":2}`)
+				r.Header.Set(HeaderCanonicalization, "other")
+				return r
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validator(tt.r); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	assert.Equal(t, "jcs", newRequest("application/json", `{}`).Header.Get(HeaderCanonicalization))
+	assert.Equal(t, "", newRequest("text/plain", `{}`).Header.Get(HeaderCanonicalization))
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, httptest.DefaultRemoteAddr, strings.NewReader(`{"a":`))
+	r.Header.Set("Content-Type", "application/json")
+	assert.Error(t, modifier(r))
+}
@@ -0,0 +1,196 @@
+package request
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+const (
+	// StreamingPayload 分块签名时x-auth-body-hash的值, 参与签名; 请求的签名作为第一个分块的种子签名
+	StreamingPayload = "STREAMING-AKSK-HMAC-PAYLOAD"
+	// DefaultChunkSize 分块签名默认的分块大小
+	DefaultChunkSize = 64 * 1024
+	// MaxChunkSize 服务端接受的最大分块大小
+	MaxChunkSize = 16 * 1024 * 1024
+)
+
+const (
+	// chunkSignatureParam 分块头部中签名的参数名称
+	chunkSignatureParam = "chunk-signature"
+	// chunkScope 分块签名的元素, 区分请求的签名和分块的签名
+	chunkScope = "AKSK-HMAC-CHUNK"
+)
+
+// WithStreamingPayload 返回的context用于创建请求时, 修改请求使用分块签名:
+// body按照分块编码, 每个分块携带与前一个分块的签名链接的签名, 适用于事先不知道长度的上传
+func WithStreamingPayload(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingPayloadKey, true)
+}
+
+// isStreamingPayload 请求是否使用分块签名
+func isStreamingPayload(ctx context.Context) bool {
+	v, _ := ctx.Value(streamingPayloadKey).(bool)
+	return v
+}
+
+// chunkElems 返回分块签名的元素: "AKSK-HMAC-CHUNK", 前一个分块的签名, 分块的hash值
+func chunkElems(a *core.Auth, prev string, data []byte) []string {
+	return []string{chunkScope, prev, a.EncodeToString(a.Sum(data))}
+}
+
+// chunkEncoder 分块签名的编码器
+type chunkEncoder struct {
+	r    io.Reader
+	a    *core.Auth
+	key  []byte
+	prev string
+	data []byte
+	buf  bytes.Buffer
+	done bool
+	err  error
+}
+
+// NewChunkEncoder 返回对r分块编码的读取器, 每个分块的格式为:
+//
+//	十六进制长度;chunk-signature=签名\r\n数据\r\n
+//
+// 最后一个分块的长度为0. key为签名密钥, seed为请求的签名, size为分块的大小, 小于等于0时使用DefaultChunkSize
+func NewChunkEncoder(r io.Reader, a *core.Auth, key []byte, seed string, size int) io.Reader {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	return &chunkEncoder{r: r, a: a, key: key, prev: seed, data: make([]byte, size)}
+}
+
+// Read 实现io.Reader
+func (e *chunkEncoder) Read(p []byte) (int, error) {
+	for e.buf.Len() == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		if e.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(e.r, e.data)
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			e.done = true
+		default:
+			e.err = err
+			continue
+		}
+		if n > 0 {
+			e.writeChunk(e.data[:n])
+		}
+		if e.done {
+			e.writeChunk(nil)
+		}
+	}
+	return e.buf.Read(p)
+}
+
+// writeChunk 对分块签名并写入缓冲区
+func (e *chunkEncoder) writeChunk(data []byte) {
+	sig := e.a.EncodeToString(e.a.Hmac(e.key, chunkElems(e.a, e.prev, data)...))
+	e.prev = sig
+	fmt.Fprintf(&e.buf, "%x;%s=%s\r\n", len(data), chunkSignatureParam, sig)
+	e.buf.Write(data)
+	e.buf.WriteString("\r\n")
+}
+
+// chunkDecoder 分块签名的解码器
+type chunkDecoder struct {
+	r    *bufio.Reader
+	a    *core.Auth
+	key  []byte
+	prev string
+	data []byte
+	err  error
+}
+
+// NewChunkDecoder 返回解码NewChunkEncoder编码的数据的读取器, 读取时校验每个分块的签名,
+// 只返回签名正确的分块的数据; 签名错误或者数据在最后一个分块之前结束时返回错误
+func NewChunkDecoder(r io.Reader, a *core.Auth, key []byte, seed string) io.Reader {
+	return &chunkDecoder{r: bufio.NewReader(r), a: a, key: key, prev: seed}
+}
+
+// Read 实现io.Reader
+func (d *chunkDecoder) Read(p []byte) (int, error) {
+	for len(d.data) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.data)
+	d.data = d.data[n:]
+	return n, nil
+}
+
+// next 读取并校验下一个分块, 最后一个分块返回io.EOF
+func (d *chunkDecoder) next() error {
+	size, sig, err := d.readHeader()
+	if err != nil {
+		return err
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return errors.New("chunk truncated")
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return errors.New("chunk invalid")
+	}
+	data = data[:size]
+	if err := d.a.ValidSignatureKey(d.key, sig, chunkElems(d.a, d.prev, data)...); err != nil {
+		return errors.New("chunk signature invalid")
+	}
+	d.prev = sig
+	if size == 0 {
+		return io.EOF
+	}
+	d.data = data
+	return nil
+}
+
+// readHeader 读取分块的头部: 十六进制长度;chunk-signature=签名
+func (d *chunkDecoder) readHeader() (int, string, error) {
+	// bufio.Reader的缓冲区大小限制了头部的长度
+	line, err := d.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return 0, "", errors.New("chunk header too long")
+	} else if err != nil {
+		return 0, "", errors.New("chunk truncated")
+	}
+	s := strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r")
+	sizeStr, param, ok := strings.Cut(s, ";")
+	if !ok {
+		return 0, "", errors.New("chunk signature is empty")
+	}
+	size, err := strconv.ParseInt(sizeStr, 16, 64)
+	if err != nil || size < 0 {
+		return 0, "", errors.New("chunk size invalid")
+	}
+	if size > MaxChunkSize {
+		return 0, "", errors.New("chunk too large")
+	}
+	name, sig, ok := strings.Cut(param, "=")
+	if !ok || name != chunkSignatureParam || sig == "" {
+		return 0, "", errors.New("chunk signature is empty")
+	}
+	return int(size), sig, nil
+}
+
+// chunkBody 分块编码或者解码后的请求body, 关闭时关闭原始的body
+type chunkBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,99 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkEncoderDecoder(t *testing.T) {
+	a := core.New()
+	key := []byte("456")
+	data := strings.Repeat("helloworld", 100)
+	for _, size := range []int{0, 1, 7, 1000, 5000} {
+		b, err := ioutil.ReadAll(NewChunkEncoder(strings.NewReader(data), a, key, "seed", size))
+		if err != nil {
+			t.Fatalf("read encoder error = %v", err)
+		}
+		got, err := ioutil.ReadAll(NewChunkDecoder(bytes.NewReader(b), a, key, "seed"))
+		assert.NoError(t, err)
+		assert.Equal(t, data, string(got))
+	}
+	b, _ := ioutil.ReadAll(NewChunkEncoder(strings.NewReader(""), a, key, "seed", 0))
+	assert.True(t, bytes.HasPrefix(b, []byte("0;chunk-signature=")))
+}
+
+func TestChunkDecoderInvalid(t *testing.T) {
+	a := core.New()
+	key := []byte("456")
+	encoded, _ := ioutil.ReadAll(NewChunkEncoder(strings.NewReader("helloworld"), a, key, "seed", 4))
+	tests := []struct {
+		name string
+		b    []byte
+		seed string
+		key  []byte
+	}{
+		{name: "FailedSeed", b: encoded, seed: "other", key: key},
+		{name: "FailedKey", b: encoded, seed: "seed", key: []byte("789")},
+		{name: "FailedData", b: bytes.Replace(encoded, []byte("hell"), []byte("HELL"), 1), seed: "seed", key: key},
+		{name: "FailedTruncated", b: encoded[:len(encoded)-10], seed: "seed", key: key},
+		{name: "FailedMissingFinalChunk", b: encoded[:bytes.Index(encoded, []byte("\r\n0;"))+2], seed: "seed", key: key},
+		{name: "FailedSize", b: []byte("zz;chunk-signature=abc\r\n"), seed: "seed", key: key},
+		{name: "FailedTooLarge", b: []byte("7fffffff;chunk-signature=abc\r\n"), seed: "seed", key: key},
+		{name: "FailedNoSignature", b: []byte("4\r\nhell\r\n"), seed: "seed", key: key},
+		{name: "FailedEmpty", seed: "seed", key: key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ioutil.ReadAll(NewChunkDecoder(bytes.NewReader(tt.b), a, tt.key, tt.seed))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestStreamingPayload(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version2, ChunkSize: 3})
+	validator, _ := NewValidator(ValidatorConfig{KeyGetter: getKey})
+	newRequest := func() *http.Request {
+		ctx := WithStreamingPayload(context.TODO())
+		r, _ := http.NewRequestWithContext(ctx, http.MethodPut, httptest.DefaultRemoteAddr, strings.NewReader("helloworld"))
+		if err := modifier(r); err != nil {
+			t.Fatalf("ModifyRequest() error = %v", err)
+		}
+		return r
+	}
+
+	r := newRequest()
+	assert.Equal(t, StreamingPayload, r.Header.Get(HeaderBodyHash))
+	assert.Equal(t, int64(-1), r.ContentLength)
+	if err := validator(r); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "helloworld", string(b))
+
+	// 修改分块的数据, 读取body时返回错误
+	r = newRequest()
+	encoded, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(bytes.Replace(encoded, []byte("low"), []byte("LOW"), 1)))
+	if err := validator(r); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	_, err = ioutil.ReadAll(r.Body)
+	assert.Error(t, err)
+
+	validator, _ = NewValidator(ValidatorConfig{
+		KeyGetter:  getKey,
+		BodyPolicy: func(*http.Request) BodyPolicy { return BodyForbidden },
+	})
+	assert.Error(t, validator(newRequest()))
+}
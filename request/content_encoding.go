@@ -0,0 +1,156 @@
+package request
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+// HeaderBodyHashMode body的hash值覆盖的内容, 参与签名; 为空时覆盖传输的原始字节
+const HeaderBodyHashMode = `x-auth-body-hash-mode`
+
+// MaxDecodedBodySize 按照Content-Encoding解码后的body的最大长度, 避免压缩炸弹
+const MaxDecodedBodySize = 64 * 1024 * 1024
+
+// errDecodedBodyTooLarge 解码后的body超过MaxDecodedBodySize
+var errDecodedBodyTooLarge = errors.New("decoded body too large")
+
+// BodyHashMode body的hash值覆盖的内容
+type BodyHashMode string
+
+const (
+	// BodyHashWire hash值覆盖传输的原始字节(例如压缩后的字节), 默认的模式, 不发送x-auth-body-hash-mode
+	BodyHashWire BodyHashMode = ""
+	// BodyHashDecoded hash值覆盖按照Content-Encoding解码后的字节, 中间的代理重新压缩时签名仍然有效
+	BodyHashDecoded BodyHashMode = "decoded"
+)
+
+// valid 是否支持的模式
+func (m BodyHashMode) valid() bool {
+	return m == BodyHashWire || m == BodyHashDecoded
+}
+
+// hashedBody 返回计算hash值使用的body, BodyHashDecoded按照Content-Encoding解码
+func (m BodyHashMode) hashedBody(b []byte, header http.Header) ([]byte, error) {
+	if m != BodyHashDecoded || len(b) == 0 {
+		return b, nil
+	}
+	r, err := decodeReader(bytes.NewReader(b), header.Values("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := ioutil.ReadAll(&decodedLimitReader{r: r, n: MaxDecodedBodySize})
+	if err == errDecodedBodyTooLarge {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.New("decode body failed")
+	}
+	return decoded, nil
+}
+
+// checkBodyHashMode 校验请求的模式
+func checkBodyHashMode(m BodyHashMode) error {
+	if !m.valid() {
+		return core.Errorf("body hash mode not supported", "body hash mode %s not supported", core.Quote(string(m)))
+	}
+	return nil
+}
+
+// decodeReader 按照Content-Encoding的值解码r, 多个编码按照相反的顺序解码; 支持gzip, deflate, identity
+func decodeReader(r io.Reader, values []string) (io.Reader, error) {
+	var encodings []string
+	for _, v := range values {
+		for _, enc := range strings.Split(v, ",") {
+			if enc = strings.ToLower(strings.TrimSpace(enc)); enc != "" && enc != "identity" {
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, errors.New("decode body failed")
+			}
+			r = zr
+		case "deflate":
+			zr, err := newDeflateReader(r)
+			if err != nil {
+				return nil, err
+			}
+			r = zr
+		default:
+			return nil, core.Errorf("content encoding not supported", "content encoding %s not supported", core.Quote(encodings[i]))
+		}
+	}
+	return r, nil
+}
+
+// newDeflateReader deflate按照RFC 9110是zlib格式, 一些客户端发送没有zlib头部的原始deflate数据, 两种都接受
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(2)
+	// zlib头部: CMF的低4位为8, 并且(CMF*256+FLG)是31的倍数
+	if len(head) == 2 && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, errors.New("decode body failed")
+		}
+		return zr, nil
+	}
+	return flate.NewReader(br), nil
+}
+
+// decodedLimitReader 最多读取n个字节, 超出时返回errDecodedBodyTooLarge; 与io.LimitReader不同, 不会静默截断
+type decodedLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read 实现io.Reader
+func (l *decodedLimitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errDecodedBodyTooLarge
+	}
+	// 多读取一个字节用于判断是否超出
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		n = int(l.n)
+		l.n = -1
+		return n, errDecodedBodyTooLarge
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+// DecompressBody 按照Content-Encoding流式解码请求的body, 删除Content-Encoding头部; 应当在校验签名之后调用.
+// 解码后的body超过MaxDecodedBodySize时, 读取body返回错误
+func DecompressBody(req *http.Request) error {
+	values := req.Header.Values("Content-Encoding")
+	if len(values) == 0 || req.Body == nil {
+		return nil
+	}
+	r, err := decodeReader(req.Body, values)
+	if err != nil {
+		return err
+	}
+	req.Body = chunkBody{&decodedLimitReader{r: r, n: MaxDecodedBodySize}, req.Body}
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	return nil
+}
@@ -0,0 +1,160 @@
+package request
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// compress 使用level压缩s
+func compress(t *testing.T, encoding string, level int, s string) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w, _ = gzip.NewWriterLevel(&buf, level)
+	case "deflate":
+		w, _ = zlib.NewWriterLevel(&buf, level)
+	case "rawdeflate":
+		w, _ = flate.NewWriter(&buf, level)
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	io.WriteString(w, s)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeReader(t *testing.T) {
+	gz := compress(t, "gzip", gzip.BestSpeed, "helloworld")
+	tests := []struct {
+		name    string
+		b       []byte
+		values  []string
+		want    string
+		wantErr bool
+	}{
+		{name: "OkIdentity", b: []byte("helloworld"), values: []string{"identity"}, want: "helloworld"},
+		{name: "OkGzip", b: gz, values: []string{"gzip"}, want: "helloworld"},
+		{name: "OkDeflate", b: compress(t, "deflate", 9, "helloworld"), values: []string{"deflate"}, want: "helloworld"},
+		{name: "OkRawDeflate", b: compress(t, "rawdeflate", 9, "helloworld"), values: []string{"Deflate"}, want: "helloworld"},
+		{name: "OkMultiple", b: compress(t, "gzip", 9, string(gz)), values: []string{"gzip", "identity, gzip"}, want: "helloworld"},
+		{name: "FailedUnsupported", b: []byte("helloworld"), values: []string{"br"}, wantErr: true},
+		{name: "FailedGzip", b: []byte("helloworld"), values: []string{"gzip"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := decodeReader(bytes.NewReader(tt.b), tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeReader() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				b, err := ioutil.ReadAll(r)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, string(b))
+			}
+		})
+	}
+}
+
+func TestBodyHashMode(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	validator, _ := NewValidator(ValidatorConfig{KeyGetter: getKey})
+	body := "helloworld helloworld helloworld"
+	newRequest := func(mode BodyHashMode) *http.Request {
+		modifier, err := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version2, BodyHashMode: mode})
+		if err != nil {
+			t.Fatalf("NewModifier() error = %v", err)
+		}
+		r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, httptest.DefaultRemoteAddr, bytes.NewReader(compress(t, "gzip", gzip.BestSpeed, body)))
+		r.Header.Set("Content-Encoding", "gzip")
+		if err := modifier(r); err != nil {
+			t.Fatalf("ModifyRequest() error = %v", err)
+		}
+		return r
+	}
+	// recompress 模拟中间的代理重新压缩body
+	recompress := func(r *http.Request) *http.Request {
+		r.Body = ioutil.NopCloser(bytes.NewReader(compress(t, "gzip", gzip.BestCompression, body)))
+		return r
+	}
+	tests := []struct {
+		name    string
+		r       *http.Request
+		wantErr bool
+	}{
+		{name: "OkWire", r: newRequest(BodyHashWire)},
+		{name: "OkDecoded", r: newRequest(BodyHashDecoded)},
+		{name: "OkDecodedRecompressed", r: recompress(newRequest(BodyHashDecoded))},
+		{name: "FailedWireRecompressed", r: recompress(newRequest(BodyHashWire)), wantErr: true},
+		{
+			name: "FailedModeRemoved",
+			r: func() *http.Request {
+				r := newRequest(BodyHashDecoded)
+				r.Header.Del(HeaderBodyHashMode)
+				return r
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "FailedUnknownMode",
+			r: func() *http.Request {
+				r := newRequest(BodyHashDecoded)
+				r.Header.Set(HeaderBodyHashMode, "other")
+				return r
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validator(tt.r); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	assert.Equal(t, string(BodyHashDecoded), newRequest(BodyHashDecoded).Header.Get(HeaderBodyHashMode))
+	_, err := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", BodyHashMode: "other"})
+	assert.Error(t, err)
+}
+
+func TestDecompressBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(compress(t, "gzip", 9, "helloworld")))
+	r.Header.Set("Content-Encoding", "gzip")
+	assert.NoError(t, DecompressBody(r))
+	b, err := ioutil.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "helloworld", string(b))
+	assert.Equal(t, "", r.Header.Get("Content-Encoding"))
+	assert.Equal(t, int64(-1), r.ContentLength)
+
+	r = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("helloworld")))
+	r.Header.Set("Content-Encoding", "br")
+	assert.Error(t, DecompressBody(r))
+
+	// 压缩炸弹: 解码后超过MaxDecodedBodySize时返回错误, 而不是截断
+	for _, size := range []int{MaxDecodedBodySize, MaxDecodedBodySize + 1} {
+		r = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(compress(t, "gzip", 9, strings.Repeat("0", size))))
+		r.Header.Set("Content-Encoding", "gzip")
+		assert.NoError(t, DecompressBody(r))
+		n, err := io.Copy(ioutil.Discard, r.Body)
+		if size > MaxDecodedBodySize {
+			assert.EqualError(t, err, "decoded body too large")
+			assert.Equal(t, int64(MaxDecodedBodySize), n)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, int64(size), n)
+	}
+}
@@ -0,0 +1,118 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+// Explanation 请求签名的中间值, 用于调试签名不一致的问题
+type Explanation struct {
+	// 请求中的签名参数
+	Version       Version
+	Algorithm     string
+	AccessKey     string
+	Timestamp     string
+	Scope         string
+	SignedHeaders []string
+	BodyHash      string
+	Signature     string
+	// 使用请求的body计算的hash值
+	ComputedBodyHash string
+	// 待签名的字符串
+	CanonicalString string
+	// 签名密钥, 编码后的字符串
+	SigningKey string
+	// 使用私有密钥计算的签名
+	ComputedSignature string
+}
+
+// Explain 使用私有密钥sk计算请求的签名的中间值, names为签名头部的名称
+func Explain(req *http.Request, sk string, names HeaderNames, opts ...core.Option) (*Explanation, error) {
+	if sk == "" {
+		return nil, errors.New("access key is invalid")
+	}
+	a := core.New(opts...)
+	p, err := parseParams(req, names)
+	if err != nil {
+		return nil, err
+	}
+	if p.algorithm != "" {
+		// 只用于调试, 不检查算法的强度
+		alg, err := core.LookupLegacyAlgorithm(p.algorithm)
+		if err != nil {
+			return nil, err
+		}
+		a = core.New(append(opts, core.WithAlgorithm(alg))...)
+	}
+	e := &Explanation{
+		Version:       p.version,
+		Algorithm:     p.algorithm,
+		AccessKey:     p.accessKey,
+		Timestamp:     p.timestamp,
+		Scope:         p.scope,
+		SignedHeaders: p.signedHeaders,
+		BodyHash:      p.bodyHash,
+		Signature:     p.signature,
+	}
+	if req.Body != nil {
+		b, err := p.readHashedBody(a, req, spoolLimits{})
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 0 {
+			e.ComputedBodyHash = a.EncodeToString(a.Sum(b))
+		}
+	}
+	signingKey := []byte(sk)
+	if p.scope != "" {
+		sc, err := core.ParseScope(p.scope)
+		if err != nil {
+			return nil, err
+		}
+		signingKey = a.SigningKey(signingKey, sc)
+	}
+	elems := p.elems(req)
+	e.CanonicalString = a.CanonicalString(elems...)
+	e.SigningKey = a.EncodeToString(signingKey)
+	e.ComputedSignature = a.EncodeToString(a.Hmac(signingKey, elems...))
+	return e, nil
+}
+
+// SignatureMismatchError 开启调试时签名不一致返回的错误, 类似AWS的SignatureDoesNotMatch, 包含服务端的待签名字符串
+type SignatureMismatchError struct {
+	// 服务端的待签名字符串
+	CanonicalString string
+	// 服务端使用请求的body计算的hash值, 不校验body或者body为空时为空字符串
+	BodyHash string
+	err      error
+}
+
+// Error 返回签名错误和服务端的待签名字符串, 待签名字符串经过转义
+func (e *SignatureMismatchError) Error() string {
+	s := e.err.Error() + "; canonical string: " + strconv.Quote(e.CanonicalString)
+	if e.BodyHash != "" {
+		s += "; body hash: " + e.BodyHash
+	}
+	return s
+}
+
+// Unwrap 返回签名错误
+func (e *SignatureMismatchError) Unwrap() error {
+	return e.err
+}
+
+// debugEnabled 是否对访问密钥ak开启调试
+func (cfg *ValidatorConfig) debugEnabled(ak string) bool {
+	if cfg.Debug {
+		return true
+	}
+	for _, v := range cfg.DebugAccessKeys {
+		if v == ak {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package request
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	e, err := Explain(goodRequest(), "456", HeaderNames{})
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	assert.Equal(t, "123", e.AccessKey)
+	assert.Equal(t, e.BodyHash, e.ComputedBodyHash)
+	assert.Equal(t, e.Signature, e.ComputedSignature)
+	assert.Contains(t, e.CanonicalString, e.Timestamp)
+
+	e, err = Explain(goodRequest(), "789", HeaderNames{})
+	assert.NoError(t, err)
+	assert.NotEqual(t, e.Signature, e.ComputedSignature)
+
+	_, err = Explain(goodRequest(), "", HeaderNames{})
+	assert.Error(t, err)
+}
+
+func TestValidatorDebug(t *testing.T) {
+	getKey := func(ak string) (string, error) {
+		return "789", nil
+	}
+	tests := []struct {
+		name      string
+		cfg       ValidatorConfig
+		wantDebug bool
+	}{
+		{name: "Disabled", cfg: ValidatorConfig{KeyGetter: getKey}},
+		{name: "Debug", cfg: ValidatorConfig{KeyGetter: getKey, Debug: true}, wantDebug: true},
+		{name: "DebugAccessKey", cfg: ValidatorConfig{KeyGetter: getKey, DebugAccessKeys: []string{"123"}}, wantDebug: true},
+		{name: "OtherAccessKey", cfg: ValidatorConfig{KeyGetter: getKey, DebugAccessKeys: []string{"abc"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewValidator(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewValidator() error = %v", err)
+			}
+			r := goodRequest()
+			err = validator(r)
+			var mismatch *SignatureMismatchError
+			assert.Error(t, err)
+			if assert.Equal(t, tt.wantDebug, errors.As(err, &mismatch)) && tt.wantDebug {
+				e, _ := Explain(r, "789", HeaderNames{})
+				assert.Equal(t, e.CanonicalString, mismatch.CanonicalString)
+				assert.Equal(t, e.ComputedBodyHash, mismatch.BodyHash)
+				assert.Contains(t, err.Error(), mismatch.BodyHash)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+const (
+	// MaxBodyMemory 规范化multipart的body时默认在内存中保存的最大长度, 超出的部分保存在临时文件中
+	MaxBodyMemory = 32 * 1024 * 1024
+	// MaxSpoolSize 规范化multipart的body时默认保存的最大长度, 包括内存和临时文件, 超出时返回错误
+	MaxSpoolSize = 1024 * 1024 * 1024
+)
+
+// spoolLimits 规范化multipart的body时保存原始字节的限制, 为0的字段使用默认值
+type spoolLimits struct {
+	memory int64
+	max    int64
+}
+
+func (l spoolLimits) withDefaults() spoolLimits {
+	if l.memory <= 0 {
+		l.memory = MaxBodyMemory
+	}
+	if l.max <= 0 {
+		l.max = MaxSpoolSize
+	}
+	return l
+}
+
+// isForm Content-Type是否为application/x-www-form-urlencoded
+func isForm(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && t == "application/x-www-form-urlencoded"
+}
+
+// multipartBoundary 返回multipart/form-data的boundary, 不是multipart/form-data时返回空字符串
+func multipartBoundary(contentType string) string {
+	t, params, err := mime.ParseMediaType(contentType)
+	if err != nil || t != "multipart/form-data" {
+		return ""
+	}
+	return params["boundary"]
+}
+
+// canonicalForm 规范化application/x-www-form-urlencoded的body: 字段按照名称排序, 同名字段保持原来的顺序, 统一转义
+func canonicalForm(b []byte) ([]byte, error) {
+	values, err := url.ParseQuery(string(b))
+	if err != nil {
+		return nil, errors.New("form invalid")
+	}
+	return []byte(values.Encode()), nil
+}
+
+// canonicalMultipart 流式规范化multipart/form-data的body, 不受boundary和不同字段之间顺序的影响:
+// 每个分块一行, 包含字段名称, 文件名称, Content-Type和内容的hash值, 按照字段名称稳定排序后使用换行拼接,
+// 与canonicalForm一样, 同名的字段保持原来的顺序. RFC 7578已经废弃Content-Transfer-Encoding, multipart.Reader.NextPart
+// 却会隐藏并按照quoted-printable解码, 签名的内容和处理函数读取的内容不一致, 所以拒绝包含这个头部的分块
+func canonicalMultipart(a *core.Auth, r io.Reader, boundary string) ([]byte, error) {
+	mr := multipart.NewReader(r, boundary)
+	var names, lines []string
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("multipart invalid")
+		}
+		if _, ok := part.Header["Content-Transfer-Encoding"]; ok {
+			return nil, errors.New("multipart content transfer encoding not supported")
+		}
+		h := a.NewHash()
+		if _, err := io.Copy(h, part); err != nil {
+			return nil, errors.New("multipart invalid")
+		}
+		line := url.Values{}
+		line.Set("name", part.FormName())
+		line.Set("filename", part.FileName())
+		line.Set("content-type", part.Header.Get("Content-Type"))
+		line.Set("hash", a.EncodeToString(h.Sum(nil)))
+		names = append(names, part.FormName())
+		lines = append(lines, line.Encode())
+	}
+	sort.Stable(byName{names, lines})
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// byName 按照字段名称排序分块的行
+type byName struct {
+	names, lines []string
+}
+
+func (s byName) Len() int           { return len(s.names) }
+func (s byName) Less(i, j int) bool { return s.names[i] < s.names[j] }
+func (s byName) Swap(i, j int) {
+	s.names[i], s.names[j] = s.names[j], s.names[i]
+	s.lines[i], s.lines[j] = s.lines[j], s.lines[i]
+}
+
+// readMultipart 读取multipart/form-data的body并流式规范化, 读取的原始字节保存在内存或者临时文件中, 用于恢复req.Body
+func (p *authParams) readMultipart(a *core.Auth, req *http.Request, boundary string, limits spoolLimits) ([]byte, error) {
+	limits = limits.withDefaults()
+	sp := &spool{memory: limits.memory, max: limits.max}
+	tee := io.TeeReader(req.Body, sp)
+	var r io.Reader = tee
+	if p.bodyHashMode == BodyHashDecoded {
+		var err error
+		if r, err = decodeReader(tee, req.Header.Values("Content-Encoding")); err != nil {
+			sp.Close()
+			return nil, err
+		}
+	}
+	b, err := canonicalMultipart(a, r, boundary)
+	if err == nil {
+		// 读取multipart结束之后剩余的字节
+		_, err = io.Copy(ioutil.Discard, tee)
+	}
+	req.Body.Close()
+	if sp.err != nil {
+		// 超出限制时返回限制的错误, 而不是multipart的解析错误
+		err = sp.err
+	}
+	if err != nil {
+		sp.Close()
+		return nil, err
+	}
+	empty := sp.size == 0
+	if req.Body, err = sp.body(); err != nil {
+		return nil, err
+	}
+	if empty {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// spool 在内存中保存不超过memory的字节, 超出时全部转存到临时文件; 总长度超过max时返回错误
+type spool struct {
+	memory int64
+	max    int64
+	size   int64
+	buf    bytes.Buffer
+	f      *os.File
+	err    error
+}
+
+// Write 实现io.Writer
+func (s *spool) Write(p []byte) (int, error) {
+	if s.size+int64(len(p)) > s.max {
+		s.err = errors.New("body too large")
+		return 0, s.err
+	}
+	if s.f == nil && s.size+int64(len(p)) > s.memory {
+		f, err := os.CreateTemp("", "aksk-body-*")
+		if err != nil {
+			return 0, err
+		}
+		// 提前删除临时文件, 关闭后由操作系统回收; 不支持时在Close中删除
+		os.Remove(f.Name())
+		s.f = f
+		if _, err := s.buf.WriteTo(f); err != nil {
+			return 0, err
+		}
+	}
+	s.size += int64(len(p))
+	if s.f != nil {
+		return s.f.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+// body 返回读取保存的字节的body
+func (s *spool) body() (io.ReadCloser, error) {
+	if s.f == nil {
+		return ioutil.NopCloser(bytes.NewReader(s.buf.Bytes())), nil
+	}
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		s.Close()
+		return nil, errors.New("read body failed")
+	}
+	return s, nil
+}
+
+// Read 从临时文件读取
+func (s *spool) Read(p []byte) (int, error) {
+	return s.f.Read(p)
+}
+
+// Close 关闭并删除临时文件
+func (s *spool) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	os.Remove(s.f.Name())
+	return err
+}
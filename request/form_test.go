@@ -0,0 +1,199 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// part multipart/form-data的分块
+type part struct {
+	name, filename, content string
+}
+
+// newMultipart 使用boundary编码parts, 返回body和Content-Type
+func newMultipart(t *testing.T, boundary string, parts ...part) (string, string) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.SetBoundary(boundary); err != nil {
+		t.Fatalf("SetBoundary() error = %v", err)
+	}
+	for _, p := range parts {
+		h := make(textproto.MIMEHeader)
+		if p.filename != "" {
+			h.Set("Content-Disposition", `form-data; name="`+p.name+`"; filename="`+p.filename+`"`)
+			h.Set("Content-Type", "application/octet-stream")
+		} else {
+			h.Set("Content-Disposition", `form-data; name="`+p.name+`"`)
+		}
+		pw, _ := w.CreatePart(h)
+		pw.Write([]byte(p.content))
+	}
+	w.Close()
+	return buf.String(), w.FormDataContentType()
+}
+
+func TestCanonicalForm(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       string
+		want    string
+		wantErr bool
+	}{
+		{name: "OkSorted", b: "b=2&a=1&a=0", want: "a=1&a=0&b=2"},
+		{name: "OkEscaped", b: "b=%7e+x&a=%41", want: "a=A&b=~+x"},
+		{name: "FailedInvalid", b: "a=%zz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalForm([]byte(tt.b))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("canonicalForm() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestFormCanonicalization(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	validator, _ := NewValidator(ValidatorConfig{KeyGetter: getKey})
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version2, Canonicalization: CanonicalizationForm})
+	newRequest := func(contentType, body string) *http.Request {
+		r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, httptest.DefaultRemoteAddr, strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		if err := modifier(r); err != nil {
+			t.Fatalf("ModifyRequest() error = %v", err)
+		}
+		return r
+	}
+	// reencode 模拟代理重新编码body
+	reencode := func(r *http.Request, contentType, body string) *http.Request {
+		r.Header.Set("Content-Type", contentType)
+		r.Body = ioutil.NopCloser(strings.NewReader(body))
+		return r
+	}
+	const form = "application/x-www-form-urlencoded"
+	parts := []part{{name: "a", content: "1"}, {name: "file", filename: "a.txt", content: "helloworld"}}
+	body, contentType := newMultipart(t, "boundary1", parts...)
+	otherBody, otherContentType := newMultipart(t, "boundary2", parts[1], parts[0])
+	tamperedBody, tamperedContentType := newMultipart(t, "boundary2", parts[0], part{name: "file", filename: "a.txt", content: "hellowarld"})
+	roles := []part{{name: "role", content: "user"}, {name: "b", content: "1"}, {name: "role", content: "admin"}}
+	rolesBody, rolesContentType := newMultipart(t, "boundary1", roles...)
+	rolesMovedBody, rolesMovedContentType := newMultipart(t, "boundary2", roles[1], roles[0], roles[2])
+	rolesSwappedBody, rolesSwappedContentType := newMultipart(t, "boundary2", roles[2], roles[1], roles[0])
+	// 中间人添加Content-Transfer-Encoding, multipart.Reader.NextPart把=31=30=30解码为100
+	amountBody, amountContentType := newMultipart(t, "boundary1", part{name: "amount", content: "=31=30=30"})
+	qpBody := strings.Replace(amountBody, "\r\n\r\n=31", "\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n=31", 1)
+	tests := []struct {
+		name    string
+		r       *http.Request
+		wantErr bool
+	}{
+		{name: "OkForm", r: newRequest(form, "b=2&a=1")},
+		{name: "OkFormReordered", r: reencode(newRequest(form, "b=2&a=1"), form, "a=%31&b=2")},
+		{name: "OkMultipart", r: newRequest(contentType, body)},
+		{name: "OkMultipartReencoded", r: reencode(newRequest(contentType, body), otherContentType, otherBody)},
+		{name: "OkMultipartRepeatedNameMoved", r: reencode(newRequest(rolesContentType, rolesBody), rolesMovedContentType, rolesMovedBody)},
+		{name: "FailedMultipartRepeatedNameSwapped", r: reencode(newRequest(rolesContentType, rolesBody), rolesSwappedContentType, rolesSwappedBody), wantErr: true},
+		{name: "FailedFormRepeatedNameSwapped", r: reencode(newRequest(form, "role=user&role=admin"), form, "role=admin&role=user"), wantErr: true},
+		{name: "FailedFormChanged", r: reencode(newRequest(form, "b=2&a=1"), form, "a=1&b=3"), wantErr: true},
+		{name: "FailedMultipartTampered", r: reencode(newRequest(contentType, body), tamperedContentType, tamperedBody), wantErr: true},
+		{name: "FailedMultipartTransferEncoding", r: reencode(newRequest(amountContentType, amountBody), amountContentType, qpBody), wantErr: true},
+		{name: "FailedMultipartInvalid", r: reencode(newRequest(contentType, body), contentType, "helloworld"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validator(tt.r); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	// 校验之后可以再次读取原始的body
+	r := newRequest(contentType, body)
+	assert.NoError(t, validator(r))
+	b, err := ioutil.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(b))
+	assert.Equal(t, "form", r.Header.Get(HeaderCanonicalization))
+	assert.Equal(t, "", newRequest("text/plain", "b=2&a=1").Header.Get(HeaderCanonicalization))
+}
+
+func TestReadMultipart(t *testing.T) {
+	body, contentType := newMultipart(t, "boundary", part{name: "file", filename: "a.txt", content: strings.Repeat("helloworld", 100)})
+	a := core.New()
+	want, err := canonicalMultipart(a, strings.NewReader(body), "boundary")
+	assert.NoError(t, err)
+	tests := []struct {
+		name     string
+		limits   spoolLimits
+		wantFile bool
+		wantErr  bool
+	}{
+		{name: "OkMemory", limits: spoolLimits{}},
+		{name: "OkFile", limits: spoolLimits{memory: 64}, wantFile: true},
+		{name: "OkFileExactMax", limits: spoolLimits{memory: 64, max: int64(len(body))}, wantFile: true},
+		{name: "FailedTooLarge", limits: spoolLimits{memory: 64, max: 512}, wantErr: true},
+		{name: "FailedTooLargeInMemory", limits: spoolLimits{max: 512}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			r.Header.Set("Content-Type", contentType)
+			p := &authParams{canonicalization: CanonicalizationForm}
+			got, err := p.readMultipart(a, r, "boundary", tt.limits)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readMultipart() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				assert.EqualError(t, err, "body too large")
+				return
+			}
+			assert.Equal(t, want, got)
+			sp, ok := r.Body.(*spool)
+			assert.Equal(t, tt.wantFile, ok && sp.f != nil)
+			all, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, body, string(all))
+			assert.NoError(t, r.Body.Close())
+		})
+	}
+}
+
+func TestValidatorMaxSpoolSize(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version3, Canonicalization: CanonicalizationForm})
+	body, contentType := newMultipart(t, "boundary", part{name: "file", filename: "a.txt", content: strings.Repeat("helloworld", 100)})
+	tests := []struct {
+		name    string
+		cfg     ValidatorConfig
+		wantErr bool
+	}{
+		{name: "Ok", cfg: ValidatorConfig{KeyGetter: getKey, MaxBodyMemory: 64}},
+		{name: "FailedTooLarge", cfg: ValidatorConfig{KeyGetter: getKey, MaxBodyMemory: 64, MaxSpoolSize: 512}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, httptest.DefaultRemoteAddr, strings.NewReader(body))
+			r.Header.Set("Content-Type", contentType)
+			if err := modifier(r); err != nil {
+				t.Fatalf("ModifyRequest() error = %v", err)
+			}
+			validator, _ := NewValidator(tt.cfg)
+			if err := validator(r); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
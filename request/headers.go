@@ -0,0 +1,69 @@
+package request
+
+import "strings"
+
+// HeaderNames 签名使用的头部名称, 为空的字段使用默认的名称
+type HeaderNames struct {
+	AccessKey        string
+	Timestamp        string
+	Signature        string
+	BodyHash         string
+	Scope            string
+	SignedHeaders    string
+	Algorithm        string
+	Version          string
+	BodyHashMode     string
+	Canonicalization string
+}
+
+// DefaultHeaderNames 返回默认的x-auth-*头部名称
+func DefaultHeaderNames() HeaderNames {
+	return HeaderNames{
+		AccessKey:        HeaderAccessKey,
+		Timestamp:        HeaderTimestamp,
+		Signature:        HeaderSignature,
+		BodyHash:         HeaderBodyHash,
+		Scope:            HeaderScope,
+		SignedHeaders:    HeaderSignedHeaders,
+		Algorithm:        HeaderAlgorithm,
+		Version:          HeaderVersion,
+		BodyHashMode:     HeaderBodyHashMode,
+		Canonicalization: HeaderCanonicalization,
+	}
+}
+
+// NewHeaderNames 使用前缀prefix替换默认头部名称的x-auth-前缀, 例如: x-acme- 得到 x-acme-access-key
+func NewHeaderNames(prefix string) HeaderNames {
+	names := DefaultHeaderNames()
+	for _, name := range names.fields() {
+		*name = prefix + strings.TrimPrefix(*name, "x-auth-")
+	}
+	return names
+}
+
+// fields 返回所有字段的指针
+func (h *HeaderNames) fields() []*string {
+	return []*string{&h.AccessKey, &h.Timestamp, &h.Signature, &h.BodyHash, &h.Scope, &h.SignedHeaders, &h.Algorithm, &h.Version, &h.BodyHashMode, &h.Canonicalization}
+}
+
+// withDefaults 返回为空的字段使用默认名称的副本
+func (h HeaderNames) withDefaults() HeaderNames {
+	defaults := DefaultHeaderNames()
+	d := defaults.fields()
+	for i, name := range h.fields() {
+		if *name == "" {
+			*name = *d[i]
+		}
+	}
+	return h
+}
+
+// List 返回所有的头部名称
+func (h HeaderNames) List() []string {
+	h = h.withDefaults()
+	var names []string
+	for _, name := range h.fields() {
+		names = append(names, *name)
+	}
+	return names
+}
@@ -0,0 +1,55 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHeaderNames(t *testing.T) {
+	names := NewHeaderNames("x-acme-")
+	assert.Equal(t, HeaderNames{
+		AccessKey:        "x-acme-access-key",
+		Timestamp:        "x-acme-timestamp",
+		Signature:        "x-acme-signature",
+		BodyHash:         "x-acme-body-hash",
+		Scope:            "x-acme-scope",
+		SignedHeaders:    "x-acme-signed-headers",
+		Algorithm:        "x-acme-algorithm",
+		Version:          "x-acme-version",
+		BodyHashMode:     "x-acme-body-hash-mode",
+		Canonicalization: "x-acme-canonicalization",
+	}, names)
+	assert.Equal(t, DefaultHeaderNames(), NewHeaderNames("x-auth-"))
+	assert.Equal(t, DefaultHeaderNames(), HeaderNames{}.withDefaults())
+	assert.Equal(t, "x-acme-access-key", HeaderNames{AccessKey: "x-acme-access-key"}.List()[0])
+}
+
+func TestHeaderNames(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	acme := NewHeaderNames("x-acme-")
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", HeaderNames: acme})
+	r, _ := http.NewRequestWithContext(context.TODO(), "POST", httptest.DefaultRemoteAddr, strings.NewReader("helloworld"))
+	if err := modifier(r); err != nil {
+		t.Fatalf("modifier error = %v", err)
+	}
+	assert.Equal(t, "123", r.Header.Get("x-acme-access-key"))
+	assert.Empty(t, r.Header.Get(HeaderAccessKey))
+
+	validator, _ := NewValidator(ValidatorConfig{KeyGetter: getKey, HeaderNames: acme})
+	assert.NoError(t, validator(r))
+	validator, _ = NewValidatorFunc(getKey, false)
+	assert.Error(t, validator(r))
+
+	signer, _ := NewResponseSigner(getKey, acme)
+	w := httptest.NewRecorder()
+	assert.NoError(t, signer(r, http.StatusOK, w.Header(), []byte(`ok`)))
+	w.Write([]byte(`ok`))
+	assert.NotEmpty(t, w.Header().Get("x-acme-signature"))
+	verifier, _ := NewResponseVerifier("456", acme)
+	assert.NoError(t, verifier(r, w.Result()))
+}
@@ -0,0 +1,266 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxJSONDepth 规范化JSON时的最大嵌套深度
+const maxJSONDepth = 512
+
+var errJSONInvalid = errors.New("json invalid")
+
+// CanonicalJSON 按照RFC 8785 (JSON Canonicalization Scheme)规范化JSON:
+// 对象的键按照UTF-16编码排序, 去掉空白, 数字按照ECMAScript的规则格式化, 字符串只转义必要的字符.
+// 重复的键, NaN和超出范围的数字返回错误
+func CanonicalJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := writeJCSValue(&buf, dec, 0); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errJSONInvalid
+	}
+	return buf.Bytes(), nil
+}
+
+// writeJCSValue 读取并写入下一个值
+func writeJCSValue(buf *bytes.Buffer, dec *json.Decoder, depth int) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return errJSONInvalid
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		if depth >= maxJSONDepth {
+			return errors.New("json too deep")
+		}
+		if v == '[' {
+			return writeJCSArray(buf, dec, depth+1)
+		}
+		if v == '{' {
+			return writeJCSObject(buf, dec, depth+1)
+		}
+		return errJSONInvalid
+	case string:
+		writeJCSString(buf, v)
+	case json.Number:
+		return writeJCSNumber(buf, v)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	default:
+		return errJSONInvalid
+	}
+	return nil
+}
+
+func writeJCSArray(buf *bytes.Buffer, dec *json.Decoder, depth int) error {
+	buf.WriteByte('[')
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJCSValue(buf, dec, depth); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return errJSONInvalid
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func writeJCSObject(buf *bytes.Buffer, dec *json.Decoder, depth int) error {
+	members := make(map[string][]byte)
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return errJSONInvalid
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return errJSONInvalid
+		}
+		if _, ok := members[key]; ok {
+			return errors.New("json key duplicated")
+		}
+		var value bytes.Buffer
+		if err := writeJCSValue(&value, dec, depth); err != nil {
+			return err
+		}
+		members[key] = value.Bytes()
+		keys = append(keys, key)
+	}
+	if _, err := dec.Token(); err != nil {
+		return errJSONInvalid
+	}
+	// 键按照UTF-16编码单元排序
+	sort.Slice(keys, func(i, j int) bool {
+		return lessUTF16(keys[i], keys[j])
+	})
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJCSString(buf, key)
+		buf.WriteByte(':')
+		buf.Write(members[key])
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// lessUTF16 按照UTF-16编码单元比较a和b
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeJCSString 写入字符串, 只转义引号, 反斜杠和控制字符
+func writeJCSString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xf])
+			} else {
+				var b [utf8.UTFMax]byte
+				buf.Write(b[:utf8.EncodeRune(b[:], r)])
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// writeJCSNumber 按照ECMAScript的Number.prototype.toString格式化数字.
+// 转换为float64后数值改变的数字返回错误, 例如: 9007199254740993, 否则代理修改超出精度的数字时签名仍然有效
+func writeJCSNumber(buf *bytes.Buffer, n json.Number) error {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return errors.New("json number invalid")
+	}
+	if !sameDecimal(string(n), strconv.FormatFloat(f, 'e', -1, 64)) {
+		return errors.New("json number loses precision")
+	}
+	buf.WriteString(formatES6Number(f))
+	return nil
+}
+
+// sameDecimal 十进制数字a和b的数值是否相等, 不计算大的指数, 避免构造的数字消耗资源
+func sameDecimal(a, b string) bool {
+	da, ea, na, ok := normalizeDecimal(a)
+	if !ok {
+		return false
+	}
+	db, eb, nb, ok := normalizeDecimal(b)
+	if !ok {
+		return false
+	}
+	if da == "" || db == "" {
+		// 0和-0相等
+		return da == db
+	}
+	return da == db && ea == eb && na == nb
+}
+
+// normalizeDecimal 把十进制数字s表示为 ±0.digits × 10^exp, digits没有首尾的0; s为0时digits为空
+func normalizeDecimal(s string) (digits string, exp int, neg bool, ok bool) {
+	if strings.HasPrefix(s, "-") {
+		neg, s = true, s[1:]
+	}
+	mantissa, e, hasExp := strings.Cut(strings.ToLower(s), "e")
+	integer, fraction, _ := strings.Cut(mantissa, ".")
+	digits = integer + fraction
+	trimmed := strings.TrimLeft(digits, "0")
+	if trimmed == "" {
+		return "", 0, neg, true
+	}
+	if hasExp {
+		var err error
+		if exp, err = strconv.Atoi(e); err != nil {
+			return "", 0, false, false
+		}
+	}
+	exp += len(integer)
+	exp -= len(digits) - len(trimmed)
+	return strings.TrimRight(trimmed, "0"), exp, neg, true
+}
+
+// formatES6Number 返回f的ECMAScript格式: 最短的有效数字, 指数在[-6, 21)之间时不使用科学计数法
+func formatES6Number(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	var sign string
+	if f < 0 {
+		sign, f = "-", -f
+	}
+	// 最短的有效数字digits和指数, f = 0.digits × 10^n
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp, _ := strings.Cut(e, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	x, _ := strconv.Atoi(exp)
+	k, n := len(digits), x+1
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+	expSign := "+"
+	if n-1 < 0 {
+		expSign = "-"
+	}
+	exponent := strconv.Itoa(abs(n - 1))
+	if k == 1 {
+		return sign + digits + "e" + expSign + exponent
+	}
+	return sign + digits[:1] + "." + digits[1:] + "e" + expSign + exponent
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
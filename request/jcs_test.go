@@ -0,0 +1,82 @@
+package request
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{
+			// RFC 8785 3.2.2, 示例中的333333333.33333329超出float64的精度, 被拒绝, 这里使用转换后的值
+			name: "OkRFCExample",
+			in: `{
+  "numbers": [333333333.3333333, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`,
+			want: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		{
+			// RFC 8785 3.2.3, 键按照UTF-16编码单元排序
+			name: "OkSorting",
+			in:   `{"\u20ac":1,"\r":2,"\ufb33":3,"1":4,"\ud83d\ude00":5,"\u0080":6,"\u00f6":7}`,
+			want: "{\"\\r\":2,\"1\":4,\"\u0080\":6,\"\u00f6\":7,\"\u20ac\":1,\"\U0001F600\":5,\"\ufb33\":3}",
+		},
+		{name: "OkNested", in: " { \"b\" : [ {\"d\":1, \"c\":2} ], \"a\":\"<&>\" } \n", want: `{"a":"<&>","b":[{"c":2,"d":1}]}`},
+		{name: "OkScalar", in: `-0`, want: `0`},
+		{name: "FailedDuplicateKey", in: `{"a":1,"a":2}`, wantErr: true},
+		{name: "FailedTrailing", in: `{"a":1} {}`, wantErr: true},
+		{name: "FailedInvalid", in: `{"a":}`, wantErr: true},
+		{name: "FailedNumberRange", in: `1e400`, wantErr: true},
+		{name: "OkNumberExact", in: `[9007199254740992, 0.1, 1.50, 100e-2, -0.0, 0e999999999999999999999, 0.30000000000000004]`, want: `[9007199254740992,0.1,1.5,1,0,0,0.30000000000000004]`},
+		{name: "FailedNumberPrecision", in: `{"amount":9007199254740993}`, wantErr: true},
+		{name: "FailedNumberPrecisionFraction", in: `333333333.33333329`, wantErr: true},
+		{name: "FailedNumberUnderflow", in: `1e-400`, wantErr: true},
+		{name: "FailedEmpty", in: ``, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanonicalJSON([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CanonicalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, string(got))
+			}
+		})
+	}
+}
+
+func Test_formatES6Number(t *testing.T) {
+	// RFC 8785 附录B的部分测试数据
+	tests := []struct {
+		f    float64
+		want string
+	}{
+		{f: 0, want: "0"},
+		{f: 5e-324, want: "5e-324"},
+		{f: -5e-324, want: "-5e-324"},
+		{f: 1.7976931348623157e308, want: "1.7976931348623157e+308"},
+		{f: 9007199254740992, want: "9007199254740992"},
+		{f: -9007199254740992, want: "-9007199254740992"},
+		{f: 295147905179352830000, want: "295147905179352830000"},
+		{f: 1e21, want: "1e+21"},
+		{f: 1e20, want: "100000000000000000000"},
+		{f: 9.999999999999997e22, want: "9.999999999999997e+22"},
+		{f: 0.000001, want: "0.000001"},
+		{f: 1e-7, want: "1e-7"},
+		{f: 0.000001234, want: "0.000001234"},
+		{f: 4.5, want: "4.5"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatES6Number(tt.f))
+	}
+}
@@ -0,0 +1,322 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+const (
+	// HeaderAuthorization 使用Authorization头部格式时的头部名称
+	HeaderAuthorization = `Authorization`
+	// HeaderSignedHeaders 参与签名的其他头部名称, 使用分号分隔
+	HeaderSignedHeaders = `x-auth-signed-headers`
+	// AuthorizationScheme Authorization头部的认证方案
+	AuthorizationScheme = `AKSK-HMAC`
+)
+
+// Authorization头部中的参数名称
+const (
+	authParamCredential       = "Credential"
+	authParamTimestamp        = "Timestamp"
+	authParamBodyHash         = "BodyHash"
+	authParamScope            = "Scope"
+	authParamSignedHeaders    = "SignedHeaders"
+	authParamSignature        = "Signature"
+	authParamAlgorithm        = "Algorithm"
+	authParamVersion          = "Version"
+	authParamBodyHashMode     = "BodyHashMode"
+	authParamCanonicalization = "Canonicalization"
+)
+
+// Format 签名头部的格式
+type Format int
+
+const (
+	// FormatHeaders 使用x-auth-*头部, 默认的格式
+	FormatHeaders Format = iota
+	// FormatAuthorization 使用单个Authorization头部, 例如:
+	// Authorization: AKSK-HMAC Version=3, Algorithm=sha256/base64, Credential=ak, Timestamp=ts, BodyHash=hash, SignedHeaders=host;content-type, Signature=sign
+	FormatAuthorization
+)
+
+// authParams 请求中的签名参数, 与头部的格式无关
+type authParams struct {
+	version          Version
+	algorithm        string
+	accessKey        string
+	timestamp        string
+	bodyHash         string
+	bodyHashMode     BodyHashMode
+	canonicalization Canonicalization
+	scope            string
+	signedHeaders    []string
+	signature        string
+}
+
+// ParseAccessKey 返回请求声明的访问密钥, 不校验签名; 用于校验通过之后识别客户端, 例如网关向上游传递身份
+func ParseAccessKey(req *http.Request, names HeaderNames) (string, error) {
+	p, err := parseParams(req, names)
+	if err != nil {
+		return "", err
+	}
+	if p.accessKey == "" {
+		return "", errors.New("access key is empty")
+	}
+	return p.accessKey, nil
+}
+
+// parseParams 从请求中解析签名参数, Authorization头部使用AuthorizationScheme时优先使用
+func parseParams(req *http.Request, names HeaderNames) (*authParams, error) {
+	if v := req.Header.Get(HeaderAuthorization); isAuthorization(v) {
+		return parseAuthorization(v)
+	}
+	names = names.withDefaults()
+	p := &authParams{
+		accessKey:        req.Header.Get(names.AccessKey),
+		timestamp:        req.Header.Get(names.Timestamp),
+		bodyHash:         req.Header.Get(names.BodyHash),
+		scope:            req.Header.Get(names.Scope),
+		signature:        req.Header.Get(names.Signature),
+		algorithm:        req.Header.Get(names.Algorithm),
+		version:          Version(req.Header.Get(names.Version)),
+		bodyHashMode:     BodyHashMode(req.Header.Get(names.BodyHashMode)),
+		canonicalization: Canonicalization(req.Header.Get(names.Canonicalization)),
+	}
+	if v := req.Header.Get(names.SignedHeaders); v != "" {
+		p.signedHeaders = splitSignedHeaders(v)
+	}
+	return p, nil
+}
+
+// isAuthorization Authorization头部是否使用AuthorizationScheme
+func isAuthorization(v string) bool {
+	scheme := strings.Fields(v)
+	return len(scheme) > 0 && strings.EqualFold(scheme[0], AuthorizationScheme)
+}
+
+// parseAuthorization 解析Authorization头部, 参数的顺序和逗号前后的空白不影响结果
+func parseAuthorization(v string) (*authParams, error) {
+	v = strings.TrimSpace(v)
+	i := strings.IndexFunc(v, func(r rune) bool { return r == ' ' || r == '\t' })
+	if i < 0 {
+		return nil, errors.New("authorization has no parameters")
+	}
+	p := &authParams{}
+	seen := make(map[string]bool)
+	for _, kv := range strings.Split(v[i:], ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		j := strings.IndexByte(kv, '=')
+		if j <= 0 {
+			return nil, errors.New("authorization parameter invalid")
+		}
+		k, val := strings.TrimSpace(kv[:j]), strings.TrimSpace(kv[j+1:])
+		key := strings.ToLower(k)
+		if seen[key] {
+			return nil, core.Errorf("authorization parameter duplicated", "authorization parameter %s duplicated", core.Quote(k))
+		}
+		seen[key] = true
+		switch key {
+		case strings.ToLower(authParamCredential):
+			p.accessKey = val
+		case strings.ToLower(authParamTimestamp):
+			p.timestamp = val
+		case strings.ToLower(authParamBodyHash):
+			p.bodyHash = val
+		case strings.ToLower(authParamScope):
+			p.scope = val
+		case strings.ToLower(authParamSignedHeaders):
+			p.signedHeaders = splitSignedHeaders(val)
+		case strings.ToLower(authParamSignature):
+			p.signature = val
+		case strings.ToLower(authParamAlgorithm):
+			p.algorithm = val
+		case strings.ToLower(authParamVersion):
+			p.version = Version(val)
+		case strings.ToLower(authParamBodyHashMode):
+			p.bodyHashMode = BodyHashMode(val)
+		case strings.ToLower(authParamCanonicalization):
+			p.canonicalization = Canonicalization(val)
+		default:
+			// 忽略未知的参数, 便于以后扩展
+		}
+	}
+	return p, nil
+}
+
+// authorization 返回Authorization头部的值
+func (p *authParams) authorization() string {
+	var params []string
+	if p.version != VersionLegacy {
+		params = append(params, authParamVersion+"="+string(p.version))
+	}
+	if p.algorithm != "" {
+		params = append(params, authParamAlgorithm+"="+p.algorithm)
+	}
+	params = append(params, authParamCredential+"="+p.accessKey, authParamTimestamp+"="+p.timestamp)
+	if p.bodyHash != "" {
+		params = append(params, authParamBodyHash+"="+p.bodyHash)
+	}
+	if p.bodyHashMode != BodyHashWire {
+		params = append(params, authParamBodyHashMode+"="+string(p.bodyHashMode))
+	}
+	if p.canonicalization != CanonicalizationNone {
+		params = append(params, authParamCanonicalization+"="+string(p.canonicalization))
+	}
+	if p.scope != "" {
+		params = append(params, authParamScope+"="+p.scope)
+	}
+	if len(p.signedHeaders) > 0 {
+		params = append(params, authParamSignedHeaders+"="+strings.Join(p.signedHeaders, ";"))
+	}
+	params = append(params, authParamSignature+"="+p.signature)
+	return AuthorizationScheme + " " + strings.Join(params, ", ")
+}
+
+// write 按照格式f将签名参数写入请求的头部
+func (p *authParams) write(req *http.Request, f Format, names HeaderNames) {
+	if f == FormatAuthorization {
+		req.Header.Set(HeaderAuthorization, p.authorization())
+		return
+	}
+	names = names.withDefaults()
+	set := func(k, v string) {
+		if v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+	set(names.AccessKey, p.accessKey)
+	set(names.Timestamp, p.timestamp)
+	set(names.BodyHash, p.bodyHash)
+	set(names.Scope, p.scope)
+	set(names.SignedHeaders, strings.Join(p.signedHeaders, ";"))
+	set(names.Signature, p.signature)
+	set(names.Algorithm, p.algorithm)
+	set(names.Version, string(p.version))
+	set(names.BodyHashMode, string(p.bodyHashMode))
+	set(names.Canonicalization, string(p.canonicalization))
+}
+
+// elems 返回参与签名的元素; Version3只有一个元素, 见canonicalRequest
+func (p *authParams) elems(req *http.Request) []string {
+	if !p.version.less(Version3) {
+		return []string{p.canonicalRequest(req)}
+	}
+	elems := []string{p.accessKey, p.timestamp, p.bodyHash}
+	if p.scope != "" {
+		elems = append(elems, p.scope)
+	}
+	if p.algorithm != "" {
+		elems = append(elems, p.algorithm)
+	}
+	if p.version != VersionLegacy {
+		elems = append(elems, string(p.version))
+	}
+	if p.bodyHashMode != BodyHashWire {
+		elems = append(elems, string(p.bodyHashMode))
+	}
+	if p.canonicalization != CanonicalizationNone {
+		elems = append(elems, string(p.canonicalization))
+	}
+	for _, name := range p.signedHeaders {
+		elems = append(elems, name+":"+headerValue(req, name))
+	}
+	return elems
+}
+
+// canonicalRequest 返回Version3的待签名字符串: 元素按照固定的顺序排列, 每个元素为"长度:值"并以换行结尾,
+// 可选的元素为空时同样占位; 签名头部的名称列表参与签名, 之后是每个签名头部的名称和值
+func (p *authParams) canonicalRequest(req *http.Request) string {
+	var b strings.Builder
+	write := func(s string) {
+		b.WriteString(strconv.Itoa(len(s)))
+		b.WriteByte(':')
+		b.WriteString(s)
+		b.WriteByte('\n')
+	}
+	write(string(p.version))
+	write(p.algorithm)
+	write(p.accessKey)
+	write(p.timestamp)
+	write(p.scope)
+	write(p.bodyHash)
+	write(string(p.bodyHashMode))
+	write(string(p.canonicalization))
+	write(strings.Join(p.signedHeaders, ";"))
+	for _, name := range p.signedHeaders {
+		write(name)
+		write(headerValue(req, name))
+	}
+	return b.String()
+}
+
+// readHashedBody 读取请求的body并恢复req.Body, 返回计算hash值使用的内容: 按照模式解码, 规范化,
+// 旧的签名方案去掉首尾的空白; 规范化multipart/form-data时流式读取, 文件分块不需要全部保存在内存中
+func (p *authParams) readHashedBody(a *core.Auth, req *http.Request, limits spoolLimits) ([]byte, error) {
+	if boundary := multipartBoundary(req.Header.Get("Content-Type")); p.canonicalization == CanonicalizationForm && boundary != "" {
+		b, err := p.readMultipart(a, req, boundary, limits)
+		if err != nil {
+			return nil, err
+		}
+		return p.version.canonicalBody(b), nil
+	}
+	b, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = p.bodyHashMode.hashedBody(b, req.Header); err != nil {
+		return nil, err
+	}
+	if b, err = p.canonicalization.canonicalize(a, b, req.Header); err != nil {
+		return nil, err
+	}
+	return p.version.canonicalBody(b), nil
+}
+
+// signedBody 签名是否包含body的hash值
+func (p *authParams) signedBody() bool {
+	return p.bodyHash != UnsignedPayload && p.bodyHash != StreamingPayload && p.bodyHash != TrailerPayload
+}
+
+// splitSignedHeaders 解析分号分隔的头部名称, 转换为小写并排序
+func splitSignedHeaders(v string) []string {
+	var names []string
+	for _, name := range strings.Split(v, ";") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// headerValue 返回参与签名的头部的值, 多个值使用逗号拼接; host 使用请求的Host, (request-target) 使用请求方法和路径
+func headerValue(req *http.Request, name string) string {
+	if name == HeaderRequestTarget {
+		if req.URL == nil {
+			return strings.ToLower(req.Method)
+		}
+		return strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+	}
+	if name == "host" {
+		if req.Host != "" {
+			return req.Host
+		}
+		if req.URL != nil {
+			return req.URL.Host
+		}
+		return ""
+	}
+	var values []string
+	for _, v := range req.Header.Values(name) {
+		values = append(values, strings.TrimSpace(v))
+	}
+	return strings.Join(values, ",")
+}
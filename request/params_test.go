@@ -0,0 +1,168 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseAuthorization(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       string
+		want    *authParams
+		wantErr bool
+	}{
+		{
+			name: "Ok",
+			v:    "AKSK-HMAC Credential=123, Timestamp=1570000000, BodyHash=abc=, SignedHeaders=host;content-type, Signature=xyz=",
+			want: &authParams{
+				accessKey:     "123",
+				timestamp:     "1570000000",
+				bodyHash:      "abc=",
+				signedHeaders: []string{"content-type", "host"},
+				signature:     "xyz=",
+			},
+		},
+		{
+			name: "OkOrderAndWhitespace",
+			v:    "  aksk-hmac   signature = xyz= ,timestamp=1570000000,, Credential=123 ,Scope=20261018/cn-north/storage ",
+			want: &authParams{
+				accessKey: "123",
+				timestamp: "1570000000",
+				scope:     "20261018/cn-north/storage",
+				signature: "xyz=",
+			},
+		},
+		{
+			name: "OkVersion",
+			v:    "AKSK-HMAC Version=2, Algorithm=sha256/base64, Credential=123",
+			want: &authParams{version: Version2, algorithm: "sha256/base64", accessKey: "123"},
+		},
+		{
+			name: "OkUnknownParameter",
+			v:    "AKSK-HMAC Credential=123, Region=cn-north",
+			want: &authParams{accessKey: "123"},
+		},
+		{
+			name:    "FailedNoParameters",
+			v:       "AKSK-HMAC",
+			wantErr: true,
+		},
+		{
+			name:    "FailedParameter",
+			v:       "AKSK-HMAC Credential",
+			wantErr: true,
+		},
+		{
+			name:    "FailedDuplicated",
+			v:       "AKSK-HMAC Credential=123, credential=456",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAuthorization(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseAuthorization() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_authParams_authorization(t *testing.T) {
+	p := &authParams{
+		accessKey:     "123",
+		timestamp:     "1570000000",
+		bodyHash:      "abc=",
+		scope:         "20261018/cn-north/storage",
+		signedHeaders: []string{"content-type", "host"},
+		signature:     "xyz=",
+	}
+	v := p.authorization()
+	assert.True(t, isAuthorization(v))
+	got, err := parseAuthorization(v)
+	assert.NoError(t, err)
+	assert.Equal(t, p, got)
+}
+
+func TestNewModifier(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	tests := []struct {
+		name    string
+		cfg     ModifierConfig
+		modify  func(r *http.Request)
+		wantErr bool
+	}{
+		{
+			name:   "OkAuthorization",
+			cfg:    ModifierConfig{AccessKey: "123", SecretKey: "456", Format: FormatAuthorization},
+			modify: func(r *http.Request) {},
+		},
+		{
+			name:   "OkSignedHeaders",
+			cfg:    ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version3, SignedHeaders: []string{"Content-Type", "host"}},
+			modify: func(r *http.Request) {},
+		},
+		{
+			name:   "OkAuthorizationSignedHeaders",
+			cfg:    ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version3, Format: FormatAuthorization, SignedHeaders: []string{"Content-Type", "host"}},
+			modify: func(r *http.Request) {},
+		},
+		{
+			name: "FailedSignedHeaderChanged",
+			cfg:  ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version3, SignedHeaders: []string{"Content-Type"}},
+			modify: func(r *http.Request) {
+				r.Header.Set("Content-Type", "text/html")
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedHostChanged",
+			cfg:  ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version3, Format: FormatAuthorization, SignedHeaders: []string{"host"}},
+			modify: func(r *http.Request) {
+				r.Host = "example.org"
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedAuthorizationBody",
+			cfg:  ModifierConfig{AccessKey: "123", SecretKey: "456", Format: FormatAuthorization},
+			modify: func(r *http.Request) {
+				r.Body = httptest.NewRequest("POST", "/", strings.NewReader("hello")).Body
+			},
+			wantErr: true,
+		},
+		{
+			name:    "FailedNoSecret",
+			cfg:     ModifierConfig{AccessKey: "123"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modifier, err := NewModifier(tt.cfg)
+			if err == nil {
+				r, _ := http.NewRequestWithContext(context.TODO(), "POST", "http://example.com/", strings.NewReader("helloworld"))
+				r.Header.Set("Content-Type", "application/json")
+				if err = modifier(r); err == nil {
+					if tt.cfg.Format == FormatAuthorization && r.Header.Get(HeaderAccessKey) != "" {
+						t.Errorf("expect no %s header", HeaderAccessKey)
+					}
+					tt.modify(r)
+					validator, _ := NewValidatorFunc(getKey, false)
+					err = validator(r)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
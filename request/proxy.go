@@ -0,0 +1,145 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HeaderRequestTarget 参与签名的伪头部, 值为小写的请求方法和请求的路径(包含查询参数), 例如: get /api/users?id=1
+const HeaderRequestTarget = `(request-target)`
+
+// 受信任的代理可以设置的头部
+const (
+	headerForwarded        = "Forwarded"
+	headerXForwardedHost   = "X-Forwarded-Host"
+	headerXForwardedPrefix = "X-Forwarded-Prefix"
+)
+
+// trustedProxies 受信任的代理的网段和代理负责设置的头部
+type trustedProxies struct {
+	nets    []*net.IPNet
+	headers map[string]bool
+}
+
+// parseTrustedProxies 解析受信任的代理, 支持CIDR和单个IP; headers为代理负责设置的头部, 配置代理时不能为空
+func parseTrustedProxies(cidrs, headers []string) (*trustedProxies, error) {
+	t := &trustedProxies{headers: make(map[string]bool)}
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy %q invalid", s)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			t.nets = append(t.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q invalid", s)
+		}
+		t.nets = append(t.nets, n)
+	}
+	// 代理原样转发的头部由客户端控制, 必须明确列出代理负责设置的头部
+	if len(t.nets) > 0 && len(headers) == 0 {
+		return nil, errors.New("trusted proxies require forwarded headers")
+	}
+	for _, h := range headers {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		switch h {
+		case headerForwarded, headerXForwardedHost, headerXForwardedPrefix:
+			t.headers[h] = true
+		default:
+			return nil, fmt.Errorf("forwarded header %q not supported", h)
+		}
+	}
+	return t, nil
+}
+
+// trusted 请求的对端地址是否为受信任的代理
+func (t *trustedProxies) trusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range t.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// value 返回受信任的头部h的最后一个值, 代理不负责设置的头部返回空字符串
+func (t *trustedProxies) value(header http.Header, h string) string {
+	if !t.headers[h] {
+		return ""
+	}
+	return lastValue(header.Values(h))
+}
+
+// forwardedRequest 请求来自受信任的代理时, 使用代理设置的Forwarded, X-Forwarded-Host和X-Forwarded-Prefix
+// 还原客户端请求的Host和路径, 返回的请求只用于计算签名. 头部有多个值时使用最后一个, 只有代理覆盖或者追加这些头部时,
+// 最后一个值才是代理设置的值; 代理原样转发的头部由客户端控制, 不能配置在headers中
+func (t *trustedProxies) forwardedRequest(req *http.Request) *http.Request {
+	if len(t.nets) == 0 || !t.trusted(req.RemoteAddr) {
+		return req
+	}
+	host := forwardedHost(t.value(req.Header, headerForwarded))
+	if host == "" {
+		host = t.value(req.Header, headerXForwardedHost)
+	}
+	prefix := strings.TrimRight(t.value(req.Header, headerXForwardedPrefix), "/")
+	if host == "" && prefix == "" {
+		return req
+	}
+	r := req.Clone(req.Context())
+	if host != "" {
+		r.Host = host
+		r.URL.Host = host
+	}
+	if prefix != "" {
+		if !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+		r.URL.Path = prefix + r.URL.Path
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = prefix + r.URL.RawPath
+		}
+	}
+	return r
+}
+
+// forwardedHost 返回RFC 7239 Forwarded头部的一个元素中的host
+func forwardedHost(value string) string {
+	for _, pair := range strings.Split(value, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(k, "host") {
+			return strings.Trim(v, `"`)
+		}
+	}
+	return ""
+}
+
+// lastValue 返回多个头部值中逗号分隔的最后一个值
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	v := values[len(values)-1]
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
@@ -0,0 +1,199 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	all := []string{"Forwarded", "X-Forwarded-Host", "X-Forwarded-Prefix"}
+	tests := []struct {
+		name    string
+		cidrs   []string
+		headers []string
+		addr    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "OkCIDR", cidrs: []string{"10.0.0.0/8"}, headers: all, addr: "10.1.2.3:1234", want: true},
+		{name: "OkIP", cidrs: []string{" 192.0.2.1 "}, headers: all, addr: "192.0.2.1:80", want: true},
+		{name: "OkIPv6", cidrs: []string{"fd00::/8"}, headers: all, addr: "[fd00::1]:80", want: true},
+		{name: "OkNotTrusted", cidrs: []string{"10.0.0.0/8"}, headers: all, addr: "192.0.2.1:80"},
+		{name: "OkAddrWithoutPort", cidrs: []string{"10.0.0.0/8"}, headers: all, addr: "10.0.0.1", want: true},
+		{name: "OkAddrInvalid", cidrs: []string{"10.0.0.0/8"}, headers: all, addr: "pipe"},
+		{name: "FailedCIDR", cidrs: []string{"10.0.0.0/33"}, headers: all, wantErr: true},
+		{name: "FailedIP", cidrs: []string{"ingress"}, headers: all, wantErr: true},
+		{name: "OkHeaders", cidrs: []string{"10.0.0.0/8"}, headers: []string{"x-forwarded-host", " Forwarded "}, addr: "10.0.0.1:80", want: true},
+		{name: "FailedHeaders", cidrs: []string{"10.0.0.0/8"}, headers: []string{"X-Forwarded-Proto"}, wantErr: true},
+		{name: "FailedNoHeaders", cidrs: []string{"10.0.0.0/8"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies, err := parseTrustedProxies(tt.cidrs, tt.headers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTrustedProxies() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, proxies.trusted(tt.addr))
+			}
+		})
+	}
+}
+
+func TestForwardedRequest(t *testing.T) {
+	proxies, _ := parseTrustedProxies([]string{"10.0.0.0/8"}, []string{"Forwarded", "X-Forwarded-Host", "X-Forwarded-Prefix"})
+	hostOnly, _ := parseTrustedProxies([]string{"10.0.0.0/8"}, []string{"X-Forwarded-Host"})
+	tests := []struct {
+		name       string
+		proxies    *trustedProxies
+		remoteAddr string
+		header     map[string]string
+		wantHost   string
+		wantTarget string
+	}{
+		{name: "OkNoHeaders", remoteAddr: "10.0.0.1:80", wantHost: "backend", wantTarget: "get /users?id=1"},
+		{
+			name:       "OkXForwarded",
+			remoteAddr: "10.0.0.1:80",
+			header:     map[string]string{"X-Forwarded-Host": "api.example.com", "X-Forwarded-Prefix": "/api/"},
+			wantHost:   "api.example.com",
+			wantTarget: "get /api/users?id=1",
+		},
+		{
+			name:       "OkForwarded",
+			remoteAddr: "10.0.0.1:80",
+			header:     map[string]string{"Forwarded": `for=192.0.2.1;host=evil.example.com, for=192.0.2.2;host="api.example.com";proto=https`, "X-Forwarded-Host": "other"},
+			wantHost:   "api.example.com",
+			wantTarget: "get /users?id=1",
+		},
+		{
+			name:       "OkLastValue",
+			remoteAddr: "10.0.0.1:80",
+			header:     map[string]string{"X-Forwarded-Host": "evil.example.com, api.example.com"},
+			wantHost:   "api.example.com",
+			wantTarget: "get /users?id=1",
+		},
+		{
+			name:       "OkHeaderNotTrusted",
+			proxies:    hostOnly,
+			remoteAddr: "10.0.0.1:80",
+			header:     map[string]string{"Forwarded": "host=evil.example.com", "X-Forwarded-Host": "api.example.com", "X-Forwarded-Prefix": "/admin"},
+			wantHost:   "api.example.com",
+			wantTarget: "get /users?id=1",
+		},
+		{
+			name:       "OkNotTrusted",
+			remoteAddr: "192.0.2.1:80",
+			header:     map[string]string{"X-Forwarded-Host": "api.example.com", "X-Forwarded-Prefix": "/api"},
+			wantHost:   "backend",
+			wantTarget: "get /users?id=1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://backend/users?id=1", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			p := proxies
+			if tt.proxies != nil {
+				p = tt.proxies
+			}
+			got := p.forwardedRequest(r)
+			assert.Equal(t, tt.wantHost, headerValue(got, "host"))
+			assert.Equal(t, tt.wantTarget, headerValue(got, HeaderRequestTarget))
+			// 不修改原来的请求
+			assert.Equal(t, "backend", r.Host)
+			assert.Equal(t, "/users", r.URL.Path)
+		})
+	}
+}
+
+func TestValidatorTrustedProxies(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version3, SignedHeaders: []string{"host", HeaderRequestTarget}})
+	// proxied 模拟入口代理去掉路径前缀/api并修改Host
+	proxied := func(remoteAddr string, header map[string]string) *http.Request {
+		r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, "https://api.example.com/api/users?id=1", strings.NewReader("helloworld"))
+		if err := modifier(r); err != nil {
+			t.Fatalf("ModifyRequest() error = %v", err)
+		}
+		s := httptest.NewRequest(http.MethodPost, "http://backend:8080/users?id=1", strings.NewReader("helloworld"))
+		s.Header = r.Header.Clone()
+		s.RemoteAddr = remoteAddr
+		for k, v := range header {
+			s.Header.Set(k, v)
+		}
+		return s
+	}
+	forwarded := map[string]string{"X-Forwarded-Host": "api.example.com", "X-Forwarded-Prefix": "/api"}
+	validator, err := NewValidator(ValidatorConfig{
+		KeyGetter:        getKey,
+		TrustedProxies:   []string{"10.0.0.0/8"},
+		ForwardedHeaders: []string{"Forwarded", "X-Forwarded-Host", "X-Forwarded-Prefix"},
+	})
+	assert.NoError(t, err)
+	tests := []struct {
+		name    string
+		r       *http.Request
+		wantErr bool
+	}{
+		{name: "OkTrusted", r: proxied("10.0.0.1:1234", forwarded)},
+		{name: "OkForwarded", r: proxied("10.0.0.1:1234", map[string]string{"Forwarded": "host=api.example.com;proto=https", "X-Forwarded-Prefix": "/api"})},
+		{name: "FailedNotTrusted", r: proxied("192.0.2.1:1234", forwarded), wantErr: true},
+		{name: "FailedNoHeaders", r: proxied("10.0.0.1:1234", nil), wantErr: true},
+		{name: "FailedPrefixChanged", r: proxied("10.0.0.1:1234", map[string]string{"X-Forwarded-Host": "api.example.com", "X-Forwarded-Prefix": "/admin"}), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validator(tt.r); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	_, err = NewValidator(ValidatorConfig{KeyGetter: getKey, TrustedProxies: []string{"ingress"}, ForwardedHeaders: []string{"X-Forwarded-Host"}})
+	assert.Error(t, err)
+	_, err = NewValidator(ValidatorConfig{KeyGetter: getKey, TrustedProxies: []string{"10.0.0.0/8"}})
+	assert.EqualError(t, err, "trusted proxies require forwarded headers")
+	assert.Error(t, err)
+	_, err = NewValidator(ValidatorConfig{KeyGetter: getKey, TrustedProxies: []string{"10.0.0.0/8"}, ForwardedHeaders: []string{"X-Forwarded-Proto"}})
+	assert.Error(t, err)
+}
+
+func TestValidatorForwardedHeaders(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version3, SignedHeaders: []string{"host", HeaderRequestTarget}})
+	// 客户端签名/admin/delete, 入口代理原样转发客户端的X-Forwarded-Prefix并把路径改为/delete
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, "http://backend:8080/admin/delete", nil)
+	if err := modifier(r); err != nil {
+		t.Fatalf("ModifyRequest() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		headers []string
+		wantErr bool
+	}{
+		{name: "FailedPrefixNotTrusted", headers: []string{"X-Forwarded-Host"}, wantErr: true},
+		{name: "OkPrefixTrusted", headers: []string{"X-Forwarded-Host", "X-Forwarded-Prefix"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewValidator(ValidatorConfig{KeyGetter: getKey, TrustedProxies: []string{"10.0.0.0/8"}, ForwardedHeaders: tt.headers})
+			assert.NoError(t, err)
+			s := httptest.NewRequest(http.MethodPost, "http://backend:8080/delete", nil)
+			s.Header = r.Header.Clone()
+			s.Header.Set("X-Forwarded-Prefix", "/admin")
+			s.RemoteAddr = "10.0.0.1:1234"
+			if err := validator(s); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,11 +3,13 @@ package request
 
 import (
 	"bytes"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/qingtao/aksk/v2/core"
@@ -22,6 +24,10 @@ const (
 	HeaderSignature = `x-auth-signature`
 	// HeaderBodyHash Body的hash值,值取决于hash算法
 	HeaderBodyHash = `x-auth-body-hash`
+	// HeaderScope 签名密钥的作用范围, 格式为: 日期/区域/服务, 非空时使用派生的签名密钥
+	HeaderScope = `x-auth-scope`
+	// HeaderAlgorithm 签名算法的名称, 格式为: hash算法/编码格式, 参与签名
+	HeaderAlgorithm = `x-auth-algorithm`
 )
 
 // Modifier 接口实现修改请求
@@ -38,49 +44,166 @@ func (f ModifierFunc) ModifyRequest(req *http.Request) error {
 	return f(req)
 }
 
+// ModifierConfig 修改请求的配置
+type ModifierConfig struct {
+	// 访问密钥, 必须非空
+	AccessKey string
+	// 私有密钥, SigningKey为空时必须非空
+	SecretKey string
+	// 派生的签名密钥, 非空时不使用私有密钥, 只在Scope的日期内有效
+	SigningKey []byte
+	// 签名密钥的作用范围, SigningKey非空时有效
+	Scope core.Scope
+	// 不计算body的hash值; 只对单个请求不计算时使用WithUnsignedPayload
+	SkipBody bool
+	// 签名头部的格式
+	Format Format
+	// 参与签名的其他头部名称, host表示请求的Host; 必须使用Version3
+	SignedHeaders []string
+	// 签名头部的名称, 为空的字段使用默认的名称
+	HeaderNames HeaderNames
+	// 签名方案的版本, 为空时使用旧的签名方案; 服务端支持时应当使用Version3
+	Version Version
+	// 分块签名(WithStreamingPayload)的分块大小, 为0时使用DefaultChunkSize
+	ChunkSize int
+	// body的hash值覆盖的内容, 为空时覆盖传输的原始字节; 只用于非流式的body
+	BodyHashMode BodyHashMode
+	// 计算hash值之前对body使用的规范化方法, 只用于适用的Content-Type和非流式的body
+	Canonicalization Canonicalization
+}
+
 // NewModifierFunc 创建新的修改请求的函数
 func NewModifierFunc(ak, sk string, skipBody bool, opts ...core.Option) (ModifierFunc, error) {
-	if ak == "" {
+	return NewModifier(ModifierConfig{AccessKey: ak, SecretKey: sk, SkipBody: skipBody}, opts...)
+}
+
+// NewScopedModifierFunc 使用派生的签名密钥创建修改请求的函数, 不需要私有密钥.
+// 签名密钥只在scope的日期内有效, 超出后修改请求返回错误
+func NewScopedModifierFunc(ak string, signingKey []byte, scope core.Scope, skipBody bool, opts ...core.Option) (ModifierFunc, error) {
+	if len(signingKey) == 0 {
+		return nil, errors.New("signing key is empty")
+	}
+	return NewModifier(ModifierConfig{AccessKey: ak, SigningKey: signingKey, Scope: scope, SkipBody: skipBody}, opts...)
+}
+
+// NewModifier 使用配置创建修改请求的函数
+func NewModifier(cfg ModifierConfig, opts ...core.Option) (ModifierFunc, error) {
+	if cfg.AccessKey == "" {
 		return nil, errors.New("access key is empty")
 	}
-	if sk == "" {
-		return nil, errors.New("access key is invalid")
+	if !cfg.Version.valid() {
+		return nil, fmt.Errorf("version %s not supported", cfg.Version)
+	}
+	if !cfg.BodyHashMode.valid() {
+		return nil, fmt.Errorf("body hash mode %s not supported", cfg.BodyHashMode)
+	}
+	if !cfg.Canonicalization.valid() {
+		return nil, fmt.Errorf("canonicalization %s not supported", cfg.Canonicalization)
+	}
+	// 版本3之前签名头部的名称列表不参与签名, 元素的边界也有歧义, 签名头部可以被伪造
+	if len(cfg.SignedHeaders) > 0 && cfg.Version.less(Version3) {
+		return nil, errors.New("signed headers require version 3")
 	}
 	a := core.New(opts...)
+	var key signingKeyFunc
+	if len(cfg.SigningKey) > 0 {
+		if _, err := core.ParseScope(cfg.Scope.String()); err != nil {
+			return nil, err
+		}
+		key = func(now time.Time) ([]byte, string, error) {
+			if date := now.UTC().Format(core.ScopeDateFormat); date != cfg.Scope.Date {
+				return nil, "", fmt.Errorf("signing key of scope %s expired", cfg.Scope)
+			}
+			return cfg.SigningKey, cfg.Scope.String(), nil
+		}
+	} else {
+		if cfg.SecretKey == "" {
+			return nil, errors.New("access key is invalid")
+		}
+		sk := []byte(cfg.SecretKey)
+		key = func(now time.Time) ([]byte, string, error) {
+			sc, ok := a.Scope(now)
+			if !ok {
+				return sk, "", nil
+			}
+			return a.SigningKey(sk, sc), sc.String(), nil
+		}
+	}
+	signedHeaders := splitSignedHeaders(strings.Join(cfg.SignedHeaders, ";"))
 	modifier := func(req *http.Request) error {
-		var bodyhash string
-		if !skipBody && req.Body != nil {
-			b, err := readBody(req)
+		now := time.Now()
+		signingKey, scope, err := key(now)
+		if err != nil {
+			return err
+		}
+		p := &authParams{
+			version:       cfg.Version,
+			algorithm:     a.Algorithm(),
+			accessKey:     cfg.AccessKey,
+			timestamp:     strconv.FormatInt(now.Unix(), 10),
+			scope:         scope,
+			signedHeaders: signedHeaders,
+		}
+		streaming := !cfg.SkipBody && req.Body != nil && isStreamingPayload(req.Context())
+		trailer := !cfg.SkipBody && req.Body != nil && isTrailerPayload(req.Context())
+		if trailer && cfg.Version == VersionLegacy {
+			return errors.New("trailer payload requires version 2")
+		}
+		sum, precomputed := precomputedBodyHash(req.Context())
+		precomputed = precomputed && !cfg.SkipBody && req.Body != nil
+		if precomputed && cfg.Version == VersionLegacy {
+			// 旧的签名方案去掉body首尾的空白, 与预先计算的hash值不一致
+			return errors.New("precomputed body hash requires version 2")
+		}
+		if precomputed && len(sum) != a.NewHash().Size() {
+			return errors.New("precomputed body hash size mismatch")
+		}
+		if streaming {
+			p.bodyHash = StreamingPayload
+		} else if trailer {
+			p.bodyHash = TrailerPayload
+		} else if !cfg.SkipBody && isUnsignedPayload(req.Context()) {
+			p.bodyHash = UnsignedPayload
+		} else if precomputed {
+			p.bodyHash = a.EncodeToString(sum)
+		} else if !cfg.SkipBody && req.Body != nil {
+			p.bodyHashMode = cfg.BodyHashMode
+			// 规范化方法只用于适用的Content-Type, 例如: JCS只用于JSON
+			if cfg.Canonicalization.applicable(req.Header) {
+				p.canonicalization = cfg.Canonicalization
+			}
+			b, err := p.readHashedBody(a, req, spoolLimits{})
 			if err != nil {
 				return err
 			}
-			bodyhash = a.EncodeToString(a.Sum(b))
-			req.Body = ioutil.NopCloser(bytes.NewReader(b))
-			// body的hash头部
-			req.Header.Set(HeaderBodyHash, bodyhash)
-		}
-		// 添加ak头部
-		req.Header.Set(HeaderAccessKey, ak)
-		// 添加时间戳头部
-		ts := strconv.FormatInt(time.Now().Unix(), 10)
-		req.Header.Set(HeaderTimestamp, ts)
-		// 添加签名头部
-		b := a.Hmac([]byte(sk), []string{ak, ts, bodyhash}...)
-		req.Header.Set(HeaderSignature, a.EncodeToString(b))
+			p.bodyHash = a.EncodeToString(a.Sum(b))
+		}
+		p.signature = a.EncodeToString(a.Hmac(signingKey, p.elems(req)...))
+		p.write(req, cfg.Format, cfg.HeaderNames)
+		if streaming {
+			// 编码后的长度未知, 使用chunked传输
+			req.Body = chunkBody{NewChunkEncoder(req.Body, a, signingKey, p.signature, cfg.ChunkSize), req.Body}
+			req.ContentLength = -1
+			req.GetBody = nil
+		} else if trailer {
+			signTrailer(req, a, signingKey, p.signature, cfg.HeaderNames)
+		}
 		return nil
 	}
 	return modifier, nil
 }
 
-// readBody 读取body
+// signingKeyFunc 返回当前时间使用的签名密钥和作用范围, 作用范围为空时签名密钥即私有密钥
+type signingKeyFunc func(now time.Time) (key []byte, scope string, err error)
+
+// readBody 读取body, 并恢复r.Body为读取的原始字节
 func readBody(r *http.Request) ([]byte, error) {
 	b, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		return nil, errors.New("read body failed")
 	}
 	r.Body = ioutil.NopCloser(bytes.NewReader(b))
-
-	return bytes.TrimSpace(b), nil
+	return b, nil
 }
 
 // Validator 验证器
@@ -97,44 +220,195 @@ func (f ValidatorFunc) Validate(req *http.Request) error {
 	return f(req)
 }
 
+// ValidatorConfig 验证器的配置
+type ValidatorConfig struct {
+	// 以ak为参数查询sk, 必须非nil
+	KeyGetter core.KeyGetter
+	// 不验证body
+	SkipBody bool
+	// 签名头部的名称, 为空的字段使用默认的名称
+	HeaderNames HeaderNames
+	// 签名不一致时返回*SignatureMismatchError, 包含服务端的待签名字符串和body的hash值, 只用于对接新的客户端
+	Debug bool
+	// 只对这些访问密钥开启调试, Debug为true时对所有访问密钥开启
+	DebugAccessKeys []string
+	// 拒绝旧的签名方案(没有声明版本, body去掉首尾的空白后计算hash)的请求, 等同于MinVersion为Version2
+	RejectLegacy bool
+	// 接受的最低版本, 为空时接受所有的版本; 使用签名头部时应当设置为Version3
+	MinVersion Version
+	// 返回请求的body策略, 为nil时使用BodyOptional; SkipBody为true时不检查
+	BodyPolicy BodyPolicyFunc
+	// 返回是否接受声明UnsignedPayload的请求, 为nil时不接受; 接受时不读取和校验body, BodyForbidden仍然拒绝
+	AllowUnsignedPayload func(req *http.Request) bool
+	// 规范化multipart/form-data的body时在内存中保存的最大长度, 超出时保存在临时文件中; 为0时使用MaxBodyMemory
+	MaxBodyMemory int64
+	// 规范化multipart/form-data的body时保存的最大长度, 包括内存和临时文件, 超出时返回错误; 为0时使用MaxSpoolSize
+	MaxSpoolSize int64
+	// 受信任的代理的网段(CIDR)或者IP, 来自这些地址的请求使用ForwardedHeaders还原客户端请求的Host和路径后校验签名
+	TrustedProxies []string
+	// 受信任的代理负责设置(覆盖或者追加)的头部: Forwarded, X-Forwarded-Host, X-Forwarded-Prefix, 配置TrustedProxies时不能为空;
+	// 代理原样转发的头部由客户端控制, 不能包含在内, 例如只设置X-Forwarded-Host的代理不能包含Forwarded
+	ForwardedHeaders []string
+}
+
 // NewValidatorFunc 创键aksk的验证器
 func NewValidatorFunc(getter core.KeyGetter, skipBody bool, opts ...core.Option) (ValidatorFunc, error) {
+	return NewValidator(ValidatorConfig{KeyGetter: getter, SkipBody: skipBody}, opts...)
+}
+
+// NewValidator 使用配置创建aksk的验证器
+func NewValidator(cfg ValidatorConfig, opts ...core.Option) (ValidatorFunc, error) {
+	getter, skipBody := cfg.KeyGetter, cfg.SkipBody
 	if getter == nil {
 		return nil, errors.New("key getter is nil")
 	}
+	if !cfg.MinVersion.valid() {
+		return nil, fmt.Errorf("min version %q not supported", cfg.MinVersion)
+	}
+	minVersion := cfg.MinVersion
+	if cfg.RejectLegacy && minVersion.less(Version2) {
+		minVersion = Version2
+	}
+	limits := spoolLimits{memory: cfg.MaxBodyMemory, max: cfg.MaxSpoolSize}
+	proxies, err := parseTrustedProxies(cfg.TrustedProxies, cfg.ForwardedHeaders)
+	if err != nil {
+		return nil, err
+	}
 	a := core.New(opts...)
+	// 访问密钥不存在时使用随机的密钥计算签名, 使校验的耗时与访问密钥存在时一致
+	dummyKey := make([]byte, 32)
+	if _, err := rand.Read(dummyKey); err != nil {
+		return nil, err
+	}
 	validator := func(req *http.Request) error {
-		ak := req.Header.Get(HeaderAccessKey)
-		if ak == "" {
-			return errors.New("access key is empty")
+		p, err := parseParams(req, cfg.HeaderNames)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(p.version, minVersion); err != nil {
+			return err
+		}
+		if len(p.signedHeaders) > 0 && p.version.less(Version3) {
+			return errors.New("signed headers require version 3")
 		}
-		sk, err := getter(ak)
+		if err := checkBodyHashMode(p.bodyHashMode); err != nil {
+			return err
+		}
+		if err := checkCanonicalization(p.canonicalization); err != nil {
+			return err
+		}
+		// 使用客户端声明的签名算法
+		a, err := a.Negotiate(p.algorithm)
 		if err != nil {
-			return fmt.Errorf("getter key error %w", err)
+			return err
 		}
-		if sk == "" {
-			return errors.New("access key is invalid")
+		if p.accessKey == "" {
+			return errors.New("access key is empty")
 		}
-		ts := req.Header.Get(HeaderTimestamp)
-		if err := a.ParseTimestamp(ts); err != nil {
+		if err := a.ParseTimestamp(p.timestamp); err != nil {
 			return err
 		}
-		signature := req.Header.Get(HeaderSignature)
-		if signature == "" {
+		if p.signature == "" {
 			return errors.New("signature is empty")
 		}
-		bodyhash := req.Header.Get(HeaderBodyHash)
-		if err := a.ValidSignature(sk, signature, ak, ts, bodyhash); err != nil {
-			return err
+		var keyErr error
+		sk, err := getter(p.accessKey)
+		if err != nil {
+			// getter的错误可能包含存储的信息, 只记录在详细信息中
+			keyErr = core.Errorf("access key is invalid", "getter key %s error: %w", core.Quote(p.accessKey), err)
+		} else if sk == "" {
+			keyErr = errors.New("access key is invalid")
 		}
-		if skipBody || req.Body == nil {
-			return nil
+		signingKey := []byte(sk)
+		if keyErr != nil {
+			signingKey = dummyKey
+		}
+		if p.scope != "" {
+			sc, err := core.ParseScope(p.scope)
+			if err != nil {
+				return err
+			}
+			if err := a.ValidScope(sc, p.timestamp); err != nil {
+				return err
+			}
+			signingKey = a.SigningKey(signingKey, sc)
+		}
+		// 代理修改了Host和路径时, 使用还原的客户端请求计算签名
+		elems := p.elems(proxies.forwardedRequest(req))
+		err = a.ValidSignatureKey(signingKey, p.signature, elems...)
+		if keyErr != nil {
+			return keyErr
 		}
-		b, err := readBody(req)
 		if err != nil {
-			return err
+			if !cfg.debugEnabled(p.accessKey) {
+				return err
+			}
+			e := &SignatureMismatchError{CanonicalString: a.CanonicalString(elems...), err: err}
+			if !skipBody && req.Body != nil && p.signedBody() {
+				if b, err := p.readHashedBody(a, req, limits); err == nil && len(b) > 0 {
+					e.BodyHash = a.EncodeToString(a.Sum(b))
+				}
+			}
+			return e
+		}
+		// 按照路由匹配的策略不适用于没有规范化的路径
+		if (cfg.BodyPolicy != nil || cfg.AllowUnsignedPayload != nil) && (req.URL == nil || !CanonicalPath(req.URL.Path)) {
+			return errors.New("path is not canonical")
+		}
+		policy := BodyOptional
+		if cfg.BodyPolicy != nil {
+			policy = cfg.BodyPolicy(req)
+		}
+		if p.bodyHash == StreamingPayload {
+			// 不论是否校验body, 都需要解码分块, 读取body时校验每个分块的签名
+			if err := checkUnreadBody(req, policy); err != nil {
+				return err
+			}
+			body := req.Body
+			if body == nil {
+				body = http.NoBody
+			}
+			req.Body = chunkBody{NewChunkDecoder(body, a, signingKey, p.signature), body}
+			req.ContentLength = -1
+			requireBody(req, policy)
+			return nil
+		}
+		if skipBody {
+			return nil
+		}
+		if p.bodyHash == TrailerPayload {
+			// 读取body时计算hash值, body结束时校验trailer
+			if p.version == VersionLegacy {
+				return errors.New("trailer payload requires version 2")
+			}
+			if err := checkUnreadBody(req, policy); err != nil {
+				return err
+			}
+			body := req.Body
+			if body == nil {
+				body = http.NoBody
+			}
+			verifyTrailer(req, body, a, signingKey, p.signature, cfg.HeaderNames)
+			requireBody(req, policy)
+			return nil
+		}
+		if p.bodyHash == UnsignedPayload {
+			if cfg.AllowUnsignedPayload == nil || !cfg.AllowUnsignedPayload(req) {
+				return errors.New("unsigned payload not allowed")
+			}
+			if err := checkUnreadBody(req, policy); err != nil {
+				return err
+			}
+			requireBody(req, policy)
+			return nil
+		}
+		var b []byte
+		if req.Body != nil {
+			if b, err = p.readHashedBody(a, req, limits); err != nil {
+				return err
+			}
 		}
-		return a.ValidBody(b, bodyhash)
+		return checkBody(a, b, p.bodyHash, policy)
 	}
 	return validator, nil
 }
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/qingtao/aksk/v2/core"
 )
@@ -329,3 +330,167 @@ func TestNewModifierFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestNewScopedModifierFunc(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	opts := []core.Option{core.WithScope("cn-north", "storage")}
+	a := core.New(opts...)
+	now := time.Now()
+	scope := core.NewScope(now, "cn-north", "storage")
+	tests := []struct {
+		name      string
+		modifier  func() (ModifierFunc, error)
+		validator []core.Option
+		wantErr   bool
+	}{
+		{
+			name: "OkSecretKey",
+			modifier: func() (ModifierFunc, error) {
+				return NewModifierFunc("123", "456", false, opts...)
+			},
+			validator: opts,
+		},
+		{
+			name: "OkSigningKey",
+			modifier: func() (ModifierFunc, error) {
+				return NewScopedModifierFunc("123", a.SigningKey([]byte("456"), scope), scope, false)
+			},
+			validator: opts,
+		},
+		{
+			name: "FailedScopeNotAccepted",
+			modifier: func() (ModifierFunc, error) {
+				return NewScopedModifierFunc("123", a.SigningKey([]byte("456"), scope), scope, false)
+			},
+			validator: nil,
+			wantErr:   true,
+		},
+		{
+			name: "FailedOtherService",
+			modifier: func() (ModifierFunc, error) {
+				sc := core.NewScope(now, "cn-north", "compute")
+				return NewScopedModifierFunc("123", a.SigningKey([]byte("456"), sc), sc, false)
+			},
+			validator: opts,
+			wantErr:   true,
+		},
+		{
+			name: "FailedExpired",
+			modifier: func() (ModifierFunc, error) {
+				sc := core.NewScope(now.Add(-48*time.Hour), "cn-north", "storage")
+				return NewScopedModifierFunc("123", a.SigningKey([]byte("456"), sc), sc, false)
+			},
+			validator: opts,
+			wantErr:   true,
+		},
+		{
+			name: "FailedInvalidScope",
+			modifier: func() (ModifierFunc, error) {
+				return NewScopedModifierFunc("123", []byte("456"), core.Scope{}, false)
+			},
+			validator: opts,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modifier, err := tt.modifier()
+			if err == nil {
+				r, _ := http.NewRequestWithContext(context.TODO(), "POST", httptest.DefaultRemoteAddr, strings.NewReader("helloworld"))
+				if err = modifier(r); err == nil {
+					validator, _ := NewValidatorFunc(getKey, false, tt.validator...)
+					err = validator(r)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAlgorithm(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	lookup := func(name string) core.Option {
+		alg, err := core.LookupAlgorithm(name)
+		if err != nil {
+			t.Fatalf("LookupAlgorithm error = %v", err)
+		}
+		return core.WithAlgorithm(alg)
+	}
+	legacy, _ := core.LookupLegacyAlgorithm("sha1")
+	// 服务端默认使用sha1, 同时接受sha256和sha512, 明确允许旧的客户端声明sha1
+	server := []core.Option{
+		core.WithHash(sha1.New),
+		core.WithAllowedAlgorithms("sha256/base64", "sha512/hex", "sha1/base64"),
+		core.WithLegacyAlgorithms("sha1/base64"),
+	}
+	tests := []struct {
+		name    string
+		client  []core.Option
+		format  Format
+		modify  func(r *http.Request)
+		wantErr bool
+	}{
+		{name: "OkLegacy", client: []core.Option{core.WithHash(sha1.New)}},
+		{name: "OkLegacyAlgorithm", client: []core.Option{core.WithAlgorithm(legacy)}},
+		{name: "FailedSHA3NotAllowed", client: []core.Option{lookup("sha3-256")}, wantErr: true},
+		{name: "OkSHA256", client: []core.Option{lookup("sha256")}},
+		{name: "OkSHA512Hex", client: []core.Option{lookup("sha512/hex")}, format: FormatAuthorization},
+		{name: "FailedNotAllowed", client: []core.Option{lookup("sha384")}, wantErr: true},
+		{
+			name:   "FailedDowngrade",
+			client: []core.Option{lookup("sha256")},
+			modify: func(r *http.Request) {
+				r.Header.Set(HeaderAlgorithm, "sha1/base64")
+			},
+			wantErr: true,
+		},
+		{
+			name:   "FailedRemoved",
+			client: []core.Option{lookup("sha256")},
+			modify: func(r *http.Request) {
+				r.Header.Del(HeaderAlgorithm)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Format: tt.format}, tt.client...)
+			r, _ := http.NewRequestWithContext(context.TODO(), "POST", httptest.DefaultRemoteAddr, strings.NewReader("helloworld"))
+			if err := modifier(r); err != nil {
+				t.Fatalf("modifier error = %v", err)
+			}
+			if tt.modify != nil {
+				tt.modify(r)
+			}
+			validator, _ := NewValidatorFunc(getKey, false, server...)
+			if err := validator(r); (err != nil) != tt.wantErr {
+				t.Errorf("validator error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatorUnknownKey(t *testing.T) {
+	getKey := func(ak string) (string, error) {
+		if ak == "wantErr" {
+			return "", errors.New("not found")
+		}
+		return "", nil
+	}
+	validator, _ := NewValidatorFunc(getKey, false)
+	for _, ak := range []string{"123", "wantErr"} {
+		modifier, _ := NewModifierFunc(ak, "456", false)
+		r := goodRequest()
+		modifier(r)
+		err1 := validator(r)
+		// 签名错误时返回相同的错误, 不泄露签名是否正确
+		r.Header.Set(HeaderSignature, "936a185caaa266bb9cbe981e9e05cb78cd732b0b3280eb944412bb6f8f8f07af")
+		err2 := validator(r)
+		if err1 == nil || err2 == nil || err1.Error() != err2.Error() {
+			t.Errorf("expect same errors, but got %v and %v", err1, err2)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package request
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+// 响应的签名复用请求的头部名称:
+// HeaderNames.Timestamp 为响应签名的时间戳, HeaderNames.BodyHash 为响应body的hash值, HeaderNames.Signature 为响应的签名.
+// 响应的签名绑定了请求的签名, 签名的元素为: 请求的签名, 时间戳, 状态码, 响应body的hash值.
+// 请求包含作用范围(HeaderNames.Scope)时, 响应使用与请求相同的派生签名密钥签名, 否则使用私有密钥.
+// 响应还对responseSignedHeaders中的头部签名, 名称列表写入HeaderNames.SignedHeaders; 其他的响应头部不受签名保护.
+
+// responseSignedHeaders 响应签名覆盖的头部, 响应中不存在的头部以空值参与签名
+var responseSignedHeaders = []string{"content-encoding", "content-type", "location", "set-cookie"}
+
+// UnsignedResponseError 响应没有签名, 例如服务端校验请求失败时返回的错误响应无法签名.
+// 响应的内容没有经过校验, 只能用于判断失败的原因
+type UnsignedResponseError struct {
+	// 响应的状态码
+	StatusCode int
+}
+
+func (e *UnsignedResponseError) Error() string {
+	return fmt.Sprintf("response signature is empty, status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// ResponseSigner 接口实现对响应签名
+type ResponseSigner interface {
+	// 根据请求req, 对响应的状态码和body签名, 签名头部写入header
+	SignResponse(req *http.Request, status int, header http.Header, body []byte) error
+}
+
+// ResponseSignerFunc 对响应签名的函数
+type ResponseSignerFunc func(req *http.Request, status int, header http.Header, body []byte) error
+
+// SignResponse 对响应签名
+func (f ResponseSignerFunc) SignResponse(req *http.Request, status int, header http.Header, body []byte) error {
+	return f(req, status, header, body)
+}
+
+// NewResponseSignerFunc 创建服务端对响应签名的函数, 请求必须已经通过验证
+func NewResponseSignerFunc(getter core.KeyGetter, opts ...core.Option) (ResponseSignerFunc, error) {
+	return NewResponseSigner(getter, HeaderNames{}, opts...)
+}
+
+// NewResponseSigner 创建服务端对响应签名的函数, names为签名头部的名称
+func NewResponseSigner(getter core.KeyGetter, names HeaderNames, opts ...core.Option) (ResponseSignerFunc, error) {
+	names = names.withDefaults()
+	if getter == nil {
+		return nil, errors.New("key getter is nil")
+	}
+	a := core.New(opts...)
+	signer := func(req *http.Request, status int, header http.Header, body []byte) error {
+		p, err := parseParams(req, names)
+		if err != nil {
+			return err
+		}
+		if p.accessKey == "" {
+			return errors.New("access key is empty")
+		}
+		sk, err := getter(p.accessKey)
+		if err != nil {
+			return core.Errorf("access key is invalid", "getter key %s error: %w", core.Quote(p.accessKey), err)
+		}
+		if sk == "" {
+			return errors.New("access key is invalid")
+		}
+		if p.signature == "" {
+			return errors.New("signature is empty")
+		}
+		// 使用请求声明的签名算法
+		a, err := a.Negotiate(p.algorithm)
+		if err != nil {
+			return err
+		}
+		key, err := responseKey(a, []byte(sk), p.scope)
+		if err != nil {
+			return err
+		}
+		// 提前设置net/http在写入body时自动检测的Content-Type, 否则签名之后才添加的Content-Type导致校验失败;
+		// HEAD请求同样检测, 状态码不允许body时不检测
+		if header.Get("Content-Type") == "" && len(body) > 0 && bodyAllowedForStatus(status) {
+			header.Set("Content-Type", http.DetectContentType(body))
+		}
+		// net/http不发送HEAD请求和1xx, 204, 304响应的body, 客户端收到的body为空
+		if req.Method == http.MethodHead || !bodyAllowedForStatus(status) {
+			body = nil
+		}
+		bodyhash := a.EncodeToString(a.Sum(body))
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		header.Set(names.SignedHeaders, strings.Join(responseSignedHeaders, ";"))
+		b := a.Hmac(key, p.signature, ts, strconv.Itoa(status), bodyhash, canonicalResponseHeaders(header, responseSignedHeaders))
+		header.Set(names.Timestamp, ts)
+		header.Set(names.BodyHash, bodyhash)
+		header.Set(names.Signature, a.EncodeToString(b))
+		return nil
+	}
+	return signer, nil
+}
+
+// ResponseVerifier 接口实现校验响应的签名
+type ResponseVerifier interface {
+	// 校验请求req对应的响应resp的签名, 验证失败返回非空错误
+	VerifyResponse(req *http.Request, resp *http.Response) error
+}
+
+// ResponseVerifierFunc 校验响应签名的函数
+type ResponseVerifierFunc func(req *http.Request, resp *http.Response) error
+
+// VerifyResponse 校验响应的签名
+func (f ResponseVerifierFunc) VerifyResponse(req *http.Request, resp *http.Response) error {
+	return f(req, resp)
+}
+
+// NewResponseVerifierFunc 创建客户端校验响应签名的函数, req必须是已经签名的请求
+func NewResponseVerifierFunc(sk string, opts ...core.Option) (ResponseVerifierFunc, error) {
+	return NewResponseVerifier(sk, HeaderNames{}, opts...)
+}
+
+// NewResponseVerifier 创建客户端校验响应签名的函数, names为签名头部的名称.
+// 请求包含作用范围时, 使用私有密钥派生请求的作用范围内的签名密钥校验
+func NewResponseVerifier(sk string, names HeaderNames, opts ...core.Option) (ResponseVerifierFunc, error) {
+	if sk == "" {
+		return nil, errors.New("access key is invalid")
+	}
+	return newResponseVerifier(func(a *core.Auth, scope string) ([]byte, error) {
+		return responseKey(a, []byte(sk), scope)
+	}, names, opts...)
+}
+
+// NewScopedResponseVerifier 使用派生的签名密钥创建客户端校验响应签名的函数, 不需要私有密钥,
+// 与NewScopedModifierFunc配合使用; 请求的作用范围必须为scope
+func NewScopedResponseVerifier(signingKey []byte, scope core.Scope, names HeaderNames, opts ...core.Option) (ResponseVerifierFunc, error) {
+	if len(signingKey) == 0 {
+		return nil, errors.New("signing key is empty")
+	}
+	if _, err := core.ParseScope(scope.String()); err != nil {
+		return nil, err
+	}
+	return newResponseVerifier(func(a *core.Auth, s string) ([]byte, error) {
+		if s != scope.String() {
+			return nil, fmt.Errorf("request scope %s not match %s", core.Quote(s), scope)
+		}
+		return signingKey, nil
+	}, names, opts...)
+}
+
+// responseKey 返回请求的作用范围scope对应的响应签名密钥, scope为空时使用私有密钥sk
+func responseKey(a *core.Auth, sk []byte, scope string) ([]byte, error) {
+	if scope == "" {
+		return sk, nil
+	}
+	sc, err := core.ParseScope(scope)
+	if err != nil {
+		return nil, err
+	}
+	return a.SigningKey(sk, sc), nil
+}
+
+// newResponseVerifier 创建校验响应签名的函数, key返回请求的作用范围对应的签名密钥
+func newResponseVerifier(key func(a *core.Auth, scope string) ([]byte, error), names HeaderNames, opts ...core.Option) (ResponseVerifierFunc, error) {
+	names = names.withDefaults()
+	a := core.New(opts...)
+	verifier := func(req *http.Request, resp *http.Response) error {
+		p, err := parseParams(req, names)
+		if err != nil {
+			return err
+		}
+		if p.signature == "" {
+			return errors.New("request signature is empty")
+		}
+		signingKey, err := key(a, p.scope)
+		if err != nil {
+			return err
+		}
+		ts := resp.Header.Get(names.Timestamp)
+		if ts == "" && resp.Header.Get(names.Signature) == "" {
+			return &UnsignedResponseError{StatusCode: resp.StatusCode}
+		}
+		if err := a.ParseTimestamp(ts); err != nil {
+			return err
+		}
+		sign := resp.Header.Get(names.Signature)
+		if sign == "" {
+			return &UnsignedResponseError{StatusCode: resp.StatusCode}
+		}
+		bodyhash := resp.Header.Get(names.BodyHash)
+		elems := []string{p.signature, ts, strconv.Itoa(resp.StatusCode), bodyhash}
+		// 旧的服务端不对响应头部签名
+		if v := resp.Header.Get(names.SignedHeaders); v != "" {
+			elems = append(elems, canonicalResponseHeaders(resp.Header, splitSignedHeaders(v)))
+		}
+		if err := a.ValidSignatureKey(signingKey, sign, elems...); err != nil {
+			return err
+		}
+		var b []byte
+		if resp.Body != nil {
+			b, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return errors.New("read body failed")
+			}
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+		// 响应body为空时同样需要校验, 防止body被截断
+		if ok := hmac.Equal([]byte(bodyhash), []byte(a.EncodeToString(a.Sum(b)))); !ok {
+			return errors.New("response body invalid")
+		}
+		return nil
+	}
+	return verifier, nil
+}
+
+// bodyAllowedForStatus 状态码是否允许body, 与net/http一致
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// canonicalResponseHeaders 返回响应头部的待签名字符串: 头部的名称列表, 之后是每个头部的名称, 值的个数和每个值,
+// 每个元素为"长度:值"并以换行结尾, 与canonicalRequest一样不受值中的分隔符影响
+func canonicalResponseHeaders(header http.Header, names []string) string {
+	var b strings.Builder
+	write := func(s string) {
+		b.WriteString(strconv.Itoa(len(s)))
+		b.WriteByte(':')
+		b.WriteString(s)
+		b.WriteByte('\n')
+	}
+	write(strings.Join(names, ";"))
+	for _, name := range names {
+		values := header.Values(name)
+		write(name)
+		write(strconv.Itoa(len(values)))
+		for _, v := range values {
+			write(v)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,201 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/qingtao/aksk/v2/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// signedResponse 对goodRequest签名的响应
+func signedResponse(t *testing.T, req *http.Request, status int, body []byte) *http.Response {
+	signer, err := NewResponseSignerFunc(func(ak string) (string, error) { return "456", nil })
+	if err != nil {
+		t.Fatalf("NewResponseSignerFunc error = %v", err)
+	}
+	w := httptest.NewRecorder()
+	if err := signer.SignResponse(req, status, w.Header(), body); err != nil {
+		t.Fatalf("SignResponse error = %v", err)
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+	return w.Result()
+}
+
+func TestNewResponseSignerFunc(t *testing.T) {
+	if _, err := NewResponseSignerFunc(nil); err == nil {
+		t.Errorf("NewResponseSignerFunc expect error, but got nil")
+	}
+	signer, _ := NewResponseSignerFunc(func(ak string) (string, error) { return "456", nil })
+	r, _ := http.NewRequestWithContext(context.TODO(), "GET", httptest.DefaultRemoteAddr, nil)
+	if err := signer(r, http.StatusOK, http.Header{}, nil); err == nil {
+		t.Errorf("SignResponse unsigned request expect error, but got nil")
+	}
+}
+
+func TestNewResponseVerifierFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(resp *http.Response)
+		wantErr bool
+	}{
+		{
+			name:    "Good",
+			modify:  func(resp *http.Response) {},
+			wantErr: false,
+		},
+		{
+			name: "FailedBody",
+			modify: func(resp *http.Response) {
+				resp.Body = ioutil.NopCloser(bytes.NewReader([]byte(`hello`)))
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedEmptyBody",
+			modify: func(resp *http.Response) {
+				resp.Body = ioutil.NopCloser(bytes.NewReader(nil))
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedStatus",
+			modify: func(resp *http.Response) {
+				resp.StatusCode = http.StatusNotFound
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedUnsigned",
+			modify: func(resp *http.Response) {
+				resp.Header.Del(HeaderSignature)
+			},
+			wantErr: true,
+		},
+		{
+			name: "OkUnsignedHeader",
+			modify: func(resp *http.Response) {
+				resp.Header.Set("Cache-Control", "no-store")
+			},
+			wantErr: false,
+		},
+		{
+			name: "FailedContentType",
+			modify: func(resp *http.Response) {
+				resp.Header.Set("Content-Type", "text/html")
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedLocation",
+			modify: func(resp *http.Response) {
+				resp.Header.Set("Location", "https://evil.example.com/")
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedSetCookie",
+			modify: func(resp *http.Response) {
+				resp.Header.Add("Set-Cookie", "session=evil")
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedSignedHeadersRemoved",
+			modify: func(resp *http.Response) {
+				resp.Header.Del(HeaderSignedHeaders)
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedTimestamp",
+			modify: func(resp *http.Response) {
+				resp.Header.Set(HeaderTimestamp, "1570000000")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := goodRequest()
+			resp := signedResponse(t, req, http.StatusOK, []byte(`helloworld`))
+			tt.modify(resp)
+			verifier, err := NewResponseVerifierFunc("456")
+			if err != nil {
+				t.Fatalf("NewResponseVerifierFunc error = %v", err)
+			}
+			var f ResponseVerifier = verifier
+			if err := f.VerifyResponse(req, resp); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyResponse error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnsignedResponse(t *testing.T) {
+	verifier, _ := NewResponseVerifierFunc("456")
+	w := httptest.NewRecorder()
+	w.WriteHeader(http.StatusUnauthorized)
+	err := verifier(goodRequest(), w.Result())
+	var unsigned *UnsignedResponseError
+	if assert.ErrorAs(t, err, &unsigned) {
+		assert.Equal(t, http.StatusUnauthorized, unsigned.StatusCode)
+	}
+	assert.EqualError(t, err, "response signature is empty, status 401 Unauthorized")
+}
+
+func TestScopedResponse(t *testing.T) {
+	opts := []core.Option{core.WithScope("cn-north-1", "storage")}
+	a := core.New(opts...)
+	sc := core.NewScope(time.Now(), "cn-north-1", "storage")
+	signingKey := a.SigningKey([]byte("456"), sc)
+	signer, _ := NewResponseSigner(func(ak string) (string, error) { return "456", nil }, HeaderNames{}, opts...)
+	newVerifier := func(v ResponseVerifierFunc, err error) ResponseVerifierFunc {
+		if err != nil {
+			t.Fatalf("NewResponseVerifier error = %v", err)
+		}
+		return v
+	}
+	other := core.NewScope(time.Now(), "cn-north-1", "compute")
+	tests := []struct {
+		name     string
+		sk       string
+		key      []byte
+		verifier ResponseVerifierFunc
+		wantErr  bool
+	}{
+		{name: "OkSecretKey", sk: "456", verifier: newVerifier(NewResponseVerifier("456", HeaderNames{}, opts...))},
+		{name: "OkSigningKey", key: signingKey, verifier: newVerifier(NewScopedResponseVerifier(signingKey, sc, HeaderNames{}, opts...))},
+		{name: "OkSigningKeyWithSecretKey", key: signingKey, verifier: newVerifier(NewResponseVerifier("456", HeaderNames{}, opts...))},
+		{name: "FailedScopeMismatch", key: signingKey, verifier: newVerifier(NewScopedResponseVerifier(signingKey, other, HeaderNames{}, opts...)), wantErr: true},
+		{name: "FailedUnscopedRequest", sk: "456", verifier: newVerifier(NewScopedResponseVerifier(signingKey, sc, HeaderNames{}, opts...)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var modifier ModifierFunc
+			if tt.key != nil {
+				modifier, _ = NewScopedModifierFunc("123", tt.key, sc, false, opts...)
+			} else {
+				modifier, _ = NewModifierFunc("123", tt.sk, false)
+			}
+			req, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, httptest.DefaultRemoteAddr, nil)
+			if err := modifier(req); err != nil {
+				t.Fatalf("ModifyRequest() error = %v", err)
+			}
+			w := httptest.NewRecorder()
+			if err := signer.SignResponse(req, http.StatusOK, w.Header(), []byte("helloworld")); err != nil {
+				t.Fatalf("SignResponse error = %v", err)
+			}
+			w.Write([]byte("helloworld"))
+			if err := tt.verifier(req, w.Result()); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyResponse error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
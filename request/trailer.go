@@ -0,0 +1,122 @@
+package request
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+// TrailerPayload 在trailer中发送body签名时x-auth-body-hash的值, 参与签名;
+// body结束后trailer中的x-auth-body-hash为body的hash值, x-auth-signature为trailer的签名
+const TrailerPayload = "STREAMING-AKSK-HMAC-TRAILER"
+
+// trailerScope trailer签名的元素, 区分请求的签名和trailer的签名
+const trailerScope = "AKSK-HMAC-TRAILER"
+
+// WithTrailerPayload 返回的context用于创建请求时, 修改请求在读取body的同时计算hash值,
+// body结束后在trailer中发送body的hash值和签名; 只支持Version2及以后的版本
+func WithTrailerPayload(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trailerPayloadKey, true)
+}
+
+// isTrailerPayload 请求是否在trailer中发送body的签名
+func isTrailerPayload(ctx context.Context) bool {
+	v, _ := ctx.Value(trailerPayloadKey).(bool)
+	return v
+}
+
+// trailerElems 返回trailer签名的元素: "AKSK-HMAC-TRAILER", 请求的签名, body的hash值
+func trailerElems(seed, bodyHash string) []string {
+	return []string{trailerScope, seed, bodyHash}
+}
+
+// trailerSigner 读取body时计算hash值, 结束时设置trailer
+type trailerSigner struct {
+	r       io.Reader
+	h       hash.Hash
+	a       *core.Auth
+	key     []byte
+	seed    string
+	trailer http.Header
+	names   HeaderNames
+}
+
+// Read 实现io.Reader
+func (s *trailerSigner) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.h.Write(p[:n])
+	if err == io.EOF {
+		bodyHash := s.a.EncodeToString(s.h.Sum(nil))
+		s.trailer.Set(s.names.BodyHash, bodyHash)
+		s.trailer.Set(s.names.Signature, s.a.EncodeToString(s.a.Hmac(s.key, trailerElems(s.seed, bodyHash)...)))
+	}
+	return n, err
+}
+
+// signTrailer 替换请求的body, 读取结束时在trailer中设置body的hash值和签名
+func signTrailer(req *http.Request, a *core.Auth, key []byte, seed string, names HeaderNames) {
+	names = names.withDefaults()
+	// 客户端发送请求前必须声明trailer的名称
+	req.Trailer = http.Header{}
+	req.Trailer.Set(names.BodyHash, "")
+	req.Trailer.Set(names.Signature, "")
+	signer := &trailerSigner{r: req.Body, h: a.NewHash(), a: a, key: key, seed: seed, trailer: req.Trailer, names: names}
+	req.Body = chunkBody{signer, req.Body}
+	req.ContentLength = -1
+	req.GetBody = nil
+}
+
+// trailerVerifier 读取body时计算hash值, 结束时校验trailer
+type trailerVerifier struct {
+	r     io.Reader
+	h     hash.Hash
+	a     *core.Auth
+	key   []byte
+	seed  string
+	req   *http.Request
+	names HeaderNames
+	err   error
+}
+
+// Read 实现io.Reader, body结束时trailer校验失败返回错误而不是io.EOF
+func (v *trailerVerifier) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF {
+		if verr := v.verify(); verr != nil {
+			err = verr
+		}
+	}
+	v.err = err
+	return n, err
+}
+
+// verify 校验trailer的签名和body的hash值
+func (v *trailerVerifier) verify() error {
+	bodyHash := v.req.Trailer.Get(v.names.BodyHash)
+	sig := v.req.Trailer.Get(v.names.Signature)
+	if bodyHash == "" || sig == "" {
+		return errors.New("trailer signature is empty")
+	}
+	if err := v.a.ValidSignatureKey(v.key, sig, trailerElems(v.seed, bodyHash)...); err != nil {
+		return errors.New("trailer signature invalid")
+	}
+	if !hmac.Equal([]byte(bodyHash), []byte(v.a.EncodeToString(v.h.Sum(nil)))) {
+		return errors.New("body invalid")
+	}
+	return nil
+}
+
+// verifyTrailer 替换请求的body, 读取结束时校验trailer
+func verifyTrailer(req *http.Request, body io.ReadCloser, a *core.Auth, key []byte, seed string, names HeaderNames) {
+	verifier := &trailerVerifier{r: body, h: a.NewHash(), a: a, key: key, seed: seed, req: req, names: names.withDefaults()}
+	req.Body = chunkBody{verifier, body}
+}
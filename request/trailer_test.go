@@ -0,0 +1,89 @@
+package request
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrailerPayload(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	validator, _ := NewValidator(ValidatorConfig{KeyGetter: getKey})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := validator(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, err.Error())
+			return
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: Version2})
+	tests := []struct {
+		name       string
+		tamper     func(r *http.Request)
+		wantStatus int
+	}{
+		{name: "Ok", wantStatus: http.StatusOK},
+		{
+			name: "FailedBody",
+			tamper: func(r *http.Request) {
+				r.Body = ioutil.NopCloser(io.MultiReader(r.Body, strings.NewReader("!")))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "FailedNoTrailer",
+			tamper: func(r *http.Request) {
+				r.Trailer = nil
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "FailedMarker",
+			tamper: func(r *http.Request) {
+				r.Header.Set(HeaderBodyHash, UnsignedPayload)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequestWithContext(WithTrailerPayload(context.TODO()), http.MethodPut, srv.URL, strings.NewReader("helloworld"))
+			if err := modifier(r); err != nil {
+				t.Fatalf("ModifyRequest() error = %v", err)
+			}
+			assert.Equal(t, TrailerPayload, r.Header.Get(HeaderBodyHash))
+			if tt.tamper != nil {
+				tt.tamper(r)
+			}
+			resp, err := srv.Client().Do(r)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode, string(b))
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, "helloworld", string(b))
+			}
+		})
+	}
+}
+
+func TestTrailerPayloadLegacy(t *testing.T) {
+	modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456"})
+	r, _ := http.NewRequestWithContext(WithTrailerPayload(context.TODO()), http.MethodPut, httptest.DefaultRemoteAddr, strings.NewReader("helloworld"))
+	assert.Error(t, modifier(r))
+}
@@ -0,0 +1,47 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Transport 实现http.RoundTripper, 发送请求前对请求签名, 并可选的校验响应的签名
+type Transport struct {
+	// Base 实际发送请求的RoundTripper, 为nil时使用http.DefaultTransport
+	Base http.RoundTripper
+	// Modifier 对请求签名, 必须非nil
+	Modifier Modifier
+	// ResponseVerifier 校验响应的签名, 为nil时不校验
+	ResponseVerifier ResponseVerifier
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip 对请求签名后发送, 响应签名校验失败时返回错误
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Modifier == nil {
+		return nil, errors.New("modifier is nil")
+	}
+	// RoundTripper不应该修改原始的请求
+	r := req.Clone(req.Context())
+	if err := t.Modifier.ModifyRequest(r); err != nil {
+		return nil, err
+	}
+	resp, err := t.base().RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if t.ResponseVerifier == nil {
+		return resp, nil
+	}
+	if err := t.ResponseVerifier.VerifyResponse(r, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
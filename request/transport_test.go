@@ -0,0 +1,69 @@
+package request
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	validator, _ := NewValidatorFunc(getKey, false)
+	signer, _ := NewResponseSignerFunc(getKey)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := validator.Validate(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body := []byte(`hello world!`)
+		if r.URL.Path == "/unsigned" {
+			w.Write(body)
+			return
+		}
+		signer.SignResponse(r, http.StatusOK, w.Header(), body)
+		if r.URL.Path == "/tampered" {
+			body = []byte(`hello world?`)
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	modifier, _ := NewModifierFunc("123", "456", false)
+	verifier, _ := NewResponseVerifierFunc("456")
+	client := &http.Client{
+		Transport: &Transport{Modifier: modifier, ResponseVerifier: verifier},
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "Good", path: "/", wantErr: false},
+		{name: "FailedUnsigned", path: "/unsigned", wantErr: true},
+		{name: "FailedTampered", path: "/tampered", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Post(server.URL+tt.path, "text/plain", strings.NewReader("helloworld"))
+			if err != nil {
+				if tt.wantErr {
+					t.Logf("client.Post error = %v", err)
+					return
+				}
+				t.Errorf("client.Post error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			defer resp.Body.Close()
+			if tt.wantErr {
+				t.Errorf("client.Post expect error, but got nil")
+				return
+			}
+			b, _ := ioutil.ReadAll(resp.Body)
+			if s := string(b); s != "hello world!" {
+				t.Errorf("expect body %q, but got %q", "hello world!", s)
+			}
+		})
+	}
+}
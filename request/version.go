@@ -0,0 +1,62 @@
+package request
+
+import (
+	"bytes"
+
+	"github.com/qingtao/aksk/v2/core"
+)
+
+// HeaderVersion 签名方案的版本, 参与签名; 为空时使用旧的签名方案
+const HeaderVersion = `x-auth-version`
+
+// Version 签名方案的版本
+type Version string
+
+const (
+	// VersionLegacy 旧的签名方案, 不发送版本, body去掉首尾的空白后计算hash, 只用于兼容旧的客户端和服务端
+	VersionLegacy Version = ""
+	// Version2 body按照传输的原始字节计算hash, 版本号参与签名
+	Version2 Version = "2"
+	// Version3 在Version2的基础上, 签名头部的名称列表参与签名, 所有的元素按照固定的顺序使用长度前缀拼接,
+	// 元素的边界没有歧义; 使用签名头部时应当使用Version3
+	Version3 Version = "3"
+)
+
+// valid 是否支持的版本
+func (v Version) valid() bool {
+	return v == VersionLegacy || v == Version2 || v == Version3
+}
+
+// less 版本v是否低于w
+func (v Version) less(w Version) bool {
+	return v.rank() < w.rank()
+}
+
+func (v Version) rank() int {
+	switch v {
+	case Version2:
+		return 2
+	case Version3:
+		return 3
+	}
+	return 0
+}
+
+// canonicalBody 返回计算hash使用的body: 旧的签名方案去掉首尾的空白
+func (v Version) canonicalBody(b []byte) []byte {
+	if v == VersionLegacy {
+		return bytes.TrimSpace(b)
+	}
+	return b
+}
+
+// checkVersion 校验请求的版本, 不接受低于min的版本
+func checkVersion(v, min Version) error {
+	if !v.valid() {
+		return core.Errorf("version not supported", "version %s not supported", core.Quote(string(v)))
+	}
+	if v.less(min) {
+		return core.Errorf("version not supported", "version %s is lower than %s", core.Quote(string(v)), core.Quote(string(min)))
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersion(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	newRequest := func(v Version, body string) *http.Request {
+		modifier, err := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: v})
+		if err != nil {
+			t.Fatalf("NewModifier() error = %v", err)
+		}
+		r, _ := http.NewRequestWithContext(context.TODO(), "POST", httptest.DefaultRemoteAddr, strings.NewReader(body))
+		if err := modifier(r); err != nil {
+			t.Fatalf("ModifyRequest() error = %v", err)
+		}
+		return r
+	}
+	tests := []struct {
+		name    string
+		cfg     ValidatorConfig
+		r       func() *http.Request
+		wantErr bool
+	}{
+		{
+			name: "OkLegacy",
+			cfg:  ValidatorConfig{KeyGetter: getKey},
+			r:    func() *http.Request { return newRequest(VersionLegacy, " helloworld\n") },
+		},
+		{
+			name: "OkLegacyTrimmed",
+			cfg:  ValidatorConfig{KeyGetter: getKey},
+			r: func() *http.Request {
+				r := newRequest(VersionLegacy, "helloworld")
+				r.Body = ioutil.NopCloser(strings.NewReader(" helloworld\n"))
+				return r
+			},
+		},
+		{
+			name: "OkVersion2",
+			cfg:  ValidatorConfig{KeyGetter: getKey, RejectLegacy: true},
+			r:    func() *http.Request { return newRequest(Version2, " helloworld\n") },
+		},
+		{
+			name: "FailedVersion2Whitespace",
+			cfg:  ValidatorConfig{KeyGetter: getKey},
+			r: func() *http.Request {
+				r := newRequest(Version2, "helloworld")
+				r.Body = ioutil.NopCloser(strings.NewReader(" helloworld\n"))
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedDowngrade",
+			cfg:  ValidatorConfig{KeyGetter: getKey},
+			r: func() *http.Request {
+				r := newRequest(Version2, "helloworld")
+				r.Header.Del(HeaderVersion)
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name:    "FailedRejectLegacy",
+			cfg:     ValidatorConfig{KeyGetter: getKey, RejectLegacy: true},
+			r:       func() *http.Request { return newRequest(VersionLegacy, "helloworld") },
+			wantErr: true,
+		},
+		{
+			name: "OkVersion3",
+			cfg:  ValidatorConfig{KeyGetter: getKey, MinVersion: Version3},
+			r:    func() *http.Request { return newRequest(Version3, " helloworld\n") },
+		},
+		{
+			name:    "FailedMinVersion",
+			cfg:     ValidatorConfig{KeyGetter: getKey, MinVersion: Version3},
+			r:       func() *http.Request { return newRequest(Version2, "helloworld") },
+			wantErr: true,
+		},
+		{
+			name: "FailedVersion3Downgrade",
+			cfg:  ValidatorConfig{KeyGetter: getKey},
+			r: func() *http.Request {
+				r := newRequest(Version3, "helloworld")
+				r.Header.Set(HeaderVersion, string(Version2))
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name: "FailedUnknownVersion",
+			cfg:  ValidatorConfig{KeyGetter: getKey},
+			r: func() *http.Request {
+				r := newRequest(Version2, "helloworld")
+				r.Header.Set(HeaderVersion, "9")
+				return r
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, _ := NewValidator(tt.cfg)
+			if err := validator(tt.r()); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVersion3SignedHeaders(t *testing.T) {
+	getKey := func(ak string) (string, error) { return "456", nil }
+	validator, err := NewValidator(ValidatorConfig{KeyGetter: getKey})
+	assert.NoError(t, err)
+	_, err = NewValidator(ValidatorConfig{KeyGetter: getKey, MinVersion: "9"})
+	assert.Error(t, err)
+	modifier, _ := NewModifier(ModifierConfig{
+		AccessKey:     "123",
+		SecretKey:     "456",
+		Version:       Version3,
+		SignedHeaders: []string{"x-tenant", "x-user", "host", HeaderRequestTarget},
+	})
+	newRequest := func() *http.Request {
+		r, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, "http://example.com/admin/delete", nil)
+		r.Header.Set("x-tenant", "acme")
+		r.Header.Set("x-user", "alice")
+		if err := modifier(r); err != nil {
+			t.Fatalf("ModifyRequest() error = %v", err)
+		}
+		return r
+	}
+	tests := []struct {
+		name    string
+		modify  func(r *http.Request)
+		wantErr bool
+	}{
+		{name: "Ok", modify: func(r *http.Request) {}},
+		{
+			// 把x-user的名称和值拼接到x-tenant的值中, 并从列表中去掉x-user
+			name: "FailedHeaderShifted",
+			modify: func(r *http.Request) {
+				r.Header.Set("x-tenant", "acmex-user:alice")
+				r.Header.Set("x-user", "root")
+				r.Header.Set(HeaderSignedHeaders, "host;(request-target);x-tenant")
+			},
+			wantErr: true,
+		},
+		{
+			name:    "FailedHeaderRemoved",
+			modify:  func(r *http.Request) { r.Header.Set(HeaderSignedHeaders, "host;(request-target);x-tenant") },
+			wantErr: true,
+		},
+		{
+			name: "FailedHostShifted",
+			modify: func(r *http.Request) {
+				r.Host = "evil.example.com(request-target):get /admin/delete"
+				r.Header.Set(HeaderSignedHeaders, "host;x-tenant;x-user")
+			},
+			wantErr: true,
+		},
+		{name: "FailedTargetChanged", modify: func(r *http.Request) { r.URL.Path = "/delete" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRequest()
+			tt.modify(r)
+			if err := validator(r); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	// 版本3之前的签名方案不能使用签名头部
+	for _, v := range []Version{VersionLegacy, Version2} {
+		_, err := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: v, SignedHeaders: []string{"host"}})
+		assert.EqualError(t, err, "signed headers require version 3")
+		m, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: v})
+		r, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, "http://example.com/", nil)
+		assert.NoError(t, m(r))
+		r.Header.Set(HeaderSignedHeaders, "host")
+		assert.EqualError(t, validator(r), "signed headers require version 3")
+	}
+}
+
+func TestModifierKeepsBody(t *testing.T) {
+	for _, v := range []Version{VersionLegacy, Version2, Version3} {
+		modifier, _ := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: v})
+		r, _ := http.NewRequestWithContext(context.TODO(), "POST", httptest.DefaultRemoteAddr, strings.NewReader(" helloworld\n"))
+		modifier(r)
+		b, _ := readBody(r)
+		assert.Equal(t, " helloworld\n", string(b))
+	}
+	_, err := NewModifier(ModifierConfig{AccessKey: "123", SecretKey: "456", Version: "9"})
+	assert.Error(t, err)
+}